@@ -0,0 +1,61 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+var byteOrderMarks = []struct {
+	name string
+	mark []byte
+}{
+	{"UTF-8", []byte{0xEF, 0xBB, 0xBF}},
+	{"UTF-16LE", []byte{0xFF, 0xFE}},
+	{"UTF-16BE", []byte{0xFE, 0xFF}},
+}
+
+type bomReader struct {
+	*bufio.Reader
+	io.Closer
+}
+
+//Skip a byte order mark at the start of r. Returns the encoding it marked,
+//or "" when there was none.
+func stripBOM(r io.ReadCloser) (io.ReadCloser, string) {
+	br := bufio.NewReader(r)
+	start, _ := br.Peek(3)
+	for _, bom := range byteOrderMarks {
+		if bytes.HasPrefix(start, bom.mark) {
+			br.Discard(len(bom.mark))
+			return bomReader{br, r}, bom.name
+		}
+	}
+	return bomReader{br, r}, ""
+}
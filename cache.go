@@ -0,0 +1,164 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//A hash of a file as it was when -cache last saw it
+type cacheEntry struct {
+	size  int64
+	mtime int64 //nanoseconds since the epoch
+	hash  []byte
+}
+
+//The -cache, keyed by algorithm and absolute file name. It is saved, one
+//entry a line, as
+//
+//	<algo> <hash> <size> <mtime> <name>
+var cache = struct {
+	sync.Mutex
+	entries map[string]cacheEntry
+	dirty   bool
+}{entries: make(map[string]cacheEntry)}
+
+func cacheKey(algo, name string) string {
+	return algo + " " + name
+}
+
+//Files are cached by absolute path, so runs from different directories, or
+//with different relative names for the same file, share entries and never
+//mistake one file for another
+func cachePath(name string) string {
+	if abs, err := filepath.Abs(name); err == nil {
+		return abs
+	}
+	return name
+}
+
+//Read the -cache file; one that doesn't exist yet is an empty cache
+func loadCache(name string) error {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		var splits = strings.SplitN(s.Text(), " ", 5)
+		if len(splits) < 5 {
+			return newHashError("decode", name, fmt.Errorf("line %d is not a cache entry", line))
+		}
+		var e cacheEntry
+		var err error
+		if e.hash, err = hex.DecodeString(splits[1]); err == nil {
+			if e.size, err = strconv.ParseInt(splits[2], 10, 64); err == nil {
+				e.mtime, err = strconv.ParseInt(splits[3], 10, 64)
+			}
+		}
+		if err != nil {
+			return newHashError("decode", name, fmt.Errorf("line %d is not a cache entry", line))
+		}
+		cache.entries[cacheKey(splits[0], splits[4])] = e
+	}
+	if err := s.Err(); err != nil {
+		return newHashError("read", name, err)
+	}
+	return nil
+}
+
+//Look up the hashes of the named file with each algorithm in the -cache.
+//They are only returned when every one is there and the file still has the
+//size and modification time it had when they were computed, or just the
+//size with -cache-ignore-mtime. info is the file as it is now, to record
+//with hashes computed afresh.
+func cachedSums(algos []string, name string) (sums [][]byte, info os.FileInfo, ok bool) {
+	if *fCache == "" {
+		return nil, nil, false
+	}
+	info, err := os.Stat(name)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, nil, false
+	}
+
+	name = cachePath(name)
+	cache.Lock()
+	defer cache.Unlock()
+	for _, algo := range algos {
+		e, hit := cache.entries[cacheKey(algo, name)]
+		if !hit || e.size != info.Size() || e.mtime != info.ModTime().UnixNano() && !*fCacheIgnoreMtime {
+			return nil, info, false
+		}
+		sums = append(sums, e.hash)
+	}
+	return sums, info, true
+}
+
+//Remember the hashes just computed for file in the -cache, as of the time
+//it was looked up there. A file modified while it was being hashed has a
+//newer time by then, so its entry won't be used again.
+func cacheResult(file fileHash) {
+	if file.cacheInfo == nil || file.fileName == nil {
+		return
+	}
+
+	var name = cachePath(*file.fileName)
+	cache.Lock()
+	defer cache.Unlock()
+	for i, algo := range file.algos {
+		cache.entries[cacheKey(algo, name)] = cacheEntry{file.cacheInfo.Size(), file.cacheInfo.ModTime().UnixNano(), file.sums[i]}
+	}
+	cache.dirty = true
+}
+
+//Write the -cache back when anything was added to it
+func saveCache(name string) error {
+	cache.Lock()
+	defer cache.Unlock()
+	if !cache.dirty {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for key, e := range cache.entries {
+		var splits = strings.SplitN(key, " ", 2)
+		fmt.Fprintf(&buf, "%s %0x %d %d %s\n", splits[0], e.hash, e.size, e.mtime, splits[1])
+	}
+	return writeFileAtomic(name, buf.Bytes(), 0644)
+}
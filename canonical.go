@@ -0,0 +1,130 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+//Rewrite a JSON or XML document into a canonical form for -canonicalize, so
+//documents that differ only in layout hash the same.
+//
+//JSON is written compactly with object keys sorted. Numbers keep the text
+//they had, so 1 and 1.0 still differ.
+//
+//XML loses comments, processing instructions, directives and text that is
+//only whitespace; attributes are sorted and every element is written as a
+//start and end tag. Names are written with their namespace, not its prefix,
+//and the namespace declarations are left out, so the prefixes chosen don't
+//matter. The result is not W3C Canonical XML, or even XML, only a stable
+//form of the document to hash.
+func canonicalize(kind string, r io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if kind == "json" {
+		return canonicalJSON(data)
+	}
+	return canonicalXML(data)
+}
+
+func canonicalJSON(data []byte) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("not valid JSON: %s", err.Error())
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, errors.New("not valid JSON: more than one value")
+	}
+
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func canonicalXML(data []byte) ([]byte, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var buf bytes.Buffer
+	var depth int
+	name := func(n xml.Name) string {
+		if n.Space != "" {
+			return n.Space + ":" + n.Local
+		}
+		return n.Local
+	}
+
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("not valid XML: %s", err.Error())
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			depth++
+			buf.WriteString("<" + name(t.Name))
+			var attrs = t.Attr
+			sort.Slice(attrs, func(i, j int) bool { return name(attrs[i].Name) < name(attrs[j].Name) })
+			for _, a := range attrs {
+				if a.Name.Space == "xmlns" || a.Name.Space == "" && a.Name.Local == "xmlns" {
+					continue
+				}
+				buf.WriteString(" " + name(a.Name) + `="`)
+				xml.EscapeText(&buf, []byte(a.Value))
+				buf.WriteString(`"`)
+			}
+			buf.WriteString(">")
+		case xml.EndElement:
+			depth--
+			buf.WriteString("</" + name(t.Name) + ">")
+		case xml.CharData:
+			if strings.TrimSpace(string(t)) != "" {
+				xml.EscapeText(&buf, t)
+			}
+		}
+	}
+	if depth != 0 || buf.Len() == 0 {
+		return nil, errors.New("not valid XML: no complete root element")
+	}
+	return buf.Bytes(), nil
+}
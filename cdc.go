@@ -0,0 +1,271 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"math/bits"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dietsche/gohash/hashes"
+)
+
+//Content-defined chunking for -cdc. A buzhash over the last cdcWindow bytes
+//picks chunk boundaries, so an insertion early in a file only changes the
+//chunks around it. A chunk ends after at least -cdc-min bytes where the low
+//bits of the rolling hash are all zero (one chance in -cdc-avg), or after
+//-cdc-max bytes. Each chunk is hashed with -h and written as
+//
+//	<algo> <hash> <offset>+<length> <file>
+
+const cdcWindow = 64
+
+//A piece of a file and its hash
+type chunk struct {
+	offset int64
+	length int64
+	hash   []byte
+}
+
+//256 values from splitmix64 seeded with 0, so chunk boundaries are the same
+//everywhere
+var buzTable = func() (table [256]uint32) {
+	var x uint64
+	for i := range table {
+		x += 0x9E3779B97F4A7C15
+		z := x
+		z = (z ^ z>>30) * 0xBF58476D1CE4E5B9
+		z = (z ^ z>>27) * 0x94D049BB133111EB
+		table[i] = uint32(z ^ z>>31)
+	}
+	return
+}()
+
+//Check the -cdc sizes: min must hold a full window, avg must be a power of two
+func validCDCSizes() error {
+	switch {
+	case *fCDCMin < cdcWindow:
+		return fmt.Errorf("-cdc-min must be at least %d", cdcWindow)
+	case *fCDCAvg <= 0 || *fCDCAvg&(*fCDCAvg-1) != 0:
+		return fmt.Errorf("-cdc-avg must be a power of two")
+	case *fCDCMax < *fCDCMin:
+		return fmt.Errorf("-cdc-max must not be less than -cdc-min")
+	}
+	return nil
+}
+
+//Split file into content-defined chunks and hash each one
+func chunkDigest(file fileHash) ([]chunk, error) {
+	h, err := hashes.Borrow(*file.expectedHashType)
+	if err != nil {
+		return nil, newHashError("hash", file.displayName(), err)
+	}
+	defer hashes.Return(*file.expectedHashType, h)
+
+	var mask = uint32(*fCDCAvg - 1)
+	var chunks []chunk
+	var window [cdcWindow]byte
+	var roll uint32
+	var offset int64
+	var data = make([]byte, 0, *fCDCMax)
+
+	r := bufio.NewReaderSize(file.r, 1<<16)
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, readError(file.displayName(), err)
+		}
+
+		var i = len(data) % cdcWindow
+		roll = bits.RotateLeft32(roll, 1) ^ buzTable[b]
+		if len(data) >= cdcWindow {
+			//rotated by cdcWindow, which is a multiple of 32, since it went in
+			roll ^= buzTable[window[i]]
+		}
+		window[i] = b
+		data = append(data, b)
+
+		if len(data) >= *fCDCMax || len(data) >= *fCDCMin && roll&mask == 0 {
+			chunks = append(chunks, hashChunk(h, offset, data))
+			offset += int64(len(data))
+			data, roll = data[:0], 0
+		}
+	}
+	if len(data) > 0 {
+		chunks = append(chunks, hashChunk(h, offset, data))
+	}
+	return chunks, nil
+}
+
+func hashChunk(h hash.Hash, offset int64, data []byte) chunk {
+	h.Reset()
+	h.Write(data)
+	return chunk{offset, int64(len(data)), h.Sum(nil)}
+}
+
+//Parse "<algo> <hash> <offset>+<length> <file>"
+func parseChunkLine(text string) (algo string, c chunk, name string, ok bool) {
+	var splits = strings.SplitN(text, " ", 4)
+	if len(splits) < 4 || splits[3] == "" {
+		return
+	}
+	var span = strings.SplitN(splits[2], "+", 2)
+	if len(span) < 2 {
+		return
+	}
+
+	var err error
+	if c.hash, err = hex.DecodeString(splits[1]); err != nil {
+		return
+	}
+	if c.offset, err = strconv.ParseInt(span[0], 10, 64); err != nil {
+		return
+	}
+	if c.length, err = strconv.ParseInt(span[1], 10, 64); err != nil {
+		return
+	}
+	name = strings.TrimPrefix(splits[3], *fPrefix)
+	return splits[0], c, name, name != ""
+}
+
+//Read a chunk manifest, sending each file with the chunks expected of it.
+//A file's chunks are the consecutive lines naming it, or with -cdc-unordered
+//all the lines naming it.
+func readChunkManifest(in chan<- fileHash, s *bufio.Scanner) {
+	var pending *fileHash
+	var pendingName string
+	flush := func() {
+		if pending != nil {
+			in <- openListed(*pending, pendingName)
+			pending = nil
+		}
+	}
+
+	//with -cdc-unordered, every file named so far by algorithm and name
+	var files = make(map[string]*fileHash)
+	var keys, names []string
+
+	for line := 0; s.Scan(); line++ {
+		if line < headerLines {
+			continue
+		}
+		algo, c, name, ok := parseChunkLine(s.Text())
+		if !ok {
+			flush()
+			in <- fileHash{line: line, err: newHashError("decode", checkFileName(), fmt.Errorf("line %d is not of the form: hash value offset+length filename", line+1))}
+			continue
+		}
+		if err := requireAlgo(algo, name, line); err != nil {
+			flush()
+			in <- fileHash{fileName: &name, line: line, err: err}
+			continue
+		}
+		if *fCDCUnordered {
+			var key = algo + " " + name
+			if files[key] == nil {
+				files[key] = &fileHash{expectedHashType: &algo, line: line}
+				keys, names = append(keys, key), append(names, name)
+			}
+			files[key].expectedChunks = append(files[key].expectedChunks, c)
+			continue
+		}
+		if pending != nil && (name != pendingName || algo != *pending.expectedHashType) {
+			flush()
+		}
+		if pending == nil {
+			pending, pendingName = &fileHash{expectedHashType: &algo, line: line}, name
+		}
+		pending.expectedChunks = append(pending.expectedChunks, c)
+	}
+	flush()
+	for i, key := range keys {
+		in <- openListed(*files[key], names[i])
+	}
+}
+
+//Compare the chunks of file with the ones its manifest expects, reporting
+//each one that differs
+func checkChunks(file fileHash) bool {
+	if *fCDCUnordered {
+		return checkChunkSet(file)
+	}
+
+	var matched = len(file.chunks) == len(file.expectedChunks)
+	for i, want := range file.expectedChunks {
+		if i >= len(file.chunks) {
+			fmt.Fprintf(os.Stderr, "%s: chunk %d+%d is missing\n", file.displayName(), want.offset, want.length)
+			matched = false
+			continue
+		}
+		got := file.chunks[i]
+		if got.offset != want.offset || got.length != want.length || !bytes.Equal(got.hash, want.hash) {
+			fmt.Fprintf(os.Stderr, "%s: chunk %d+%d does not match\n", file.displayName(), want.offset, want.length)
+			matched = false
+		}
+	}
+	for i := len(file.expectedChunks); i < len(file.chunks); i++ {
+		fmt.Fprintf(os.Stderr, "%s: chunk %d+%d is not in the manifest\n", file.displayName(), file.chunks[i].offset, file.chunks[i].length)
+	}
+	return matched
+}
+
+//Compare the chunk hashes of file with the ones its manifest expects as a
+//multiset, for -cdc-unordered: where each chunk is doesn't matter, only that
+//every expected chunk is there as often as listed and no others are
+func checkChunkSet(file fileHash) bool {
+	var want = make(map[string]int)
+	for _, c := range file.expectedChunks {
+		want[string(c.hash)]++
+	}
+
+	var matched = true
+	for _, got := range file.chunks {
+		if want[string(got.hash)] > 0 {
+			want[string(got.hash)]--
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s: chunk %0x at %d+%d is not in the manifest\n", file.displayName(), got.hash, got.offset, got.length)
+		matched = false
+	}
+	for _, c := range file.expectedChunks {
+		if want[string(c.hash)] > 0 {
+			want[string(c.hash)]--
+			fmt.Fprintf(os.Stderr, "%s: chunk %0x is missing\n", file.displayName(), c.hash)
+			matched = false
+		}
+	}
+	return matched
+}
@@ -0,0 +1,533 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dietsche/gohash/hashes"
+)
+
+//Name of the check file; "-" or no name at all means stdin
+func checkFileName() string {
+	if flag.NArg() == 0 {
+		return "-"
+	}
+	return flag.Arg(0)
+}
+
+//Split a line of the check file into algorithm, expected hash and file name,
+//less any -prefix
+func parseCheckLine(text string) (algo, hash, name string, ok bool) {
+	if *fCompat {
+		hash, name, ok = parseCompatLine(text)
+		name = strings.TrimPrefix(name, *fPrefix)
+		if inferAlgo {
+			return algoOf(hash), hash, name, ok && name != ""
+		}
+		return *fHash, hash, name, ok && name != ""
+	}
+
+	var splits = strings.SplitN(text, " ", 3)
+	if len(splits) < 3 || strings.Contains(splits[0], ",") {
+		return "", "", "", false
+	}
+	name = strings.TrimPrefix(splits[2], *fPrefix)
+	return splits[0], splits[1], name, name != ""
+}
+
+//Split the octal permissions -verify-permissions records off the front of
+//a file name from the check file
+func splitMode(name string) (os.FileMode, string, bool) {
+	var splits = strings.SplitN(name, " ", 2)
+	if len(splits) < 2 || splits[1] == "" {
+		return 0, "", false
+	}
+	mode, err := strconv.ParseUint(splits[0], 8, 32)
+	if err != nil || os.FileMode(mode) != os.FileMode(mode).Perm() {
+		return 0, "", false
+	}
+	return os.FileMode(mode), splits[1], true
+}
+
+//Split the owner -verify-owner records off the front of a file name from
+//the check file
+func splitOwner(name string) (string, string, bool) {
+	var splits = strings.SplitN(name, " ", 2)
+	if len(splits) < 2 || splits[1] == "" || splits[0] != "-" && !strings.Contains(splits[0], ":") {
+		return "", "", false
+	}
+	return splits[0], splits[1], true
+}
+
+//Whether two owners recorded by -verify-owner are the same uid and gid,
+//whatever the names they went by. - is a file with no owner to tell, and
+//matches anything.
+func sameOwner(a, b string) bool {
+	if a == "-" || b == "-" {
+		return true
+	}
+	ids := func(owner string) string {
+		var splits = strings.SplitN(owner, ":", 2)
+		for i := range splits {
+			splits[i] = strings.SplitN(splits[i], "(", 2)[0]
+		}
+		return strings.Join(splits, ":")
+	}
+	return ids(a) == ids(b)
+}
+
+//Replace the expected hash in a line that parseCheckLine accepted
+func replaceCheckHash(text, hash string) string {
+	if *fCompat {
+		var prefix string
+		if strings.HasPrefix(text, "\\") {
+			prefix, text = "\\", text[1:]
+		}
+		return prefix + hash + text[strings.Index(text, " "):]
+	}
+
+	var splits = strings.SplitN(text, " ", 3)
+	splits[1] = hash
+	return strings.Join(splits, " ")
+}
+
+func openFilesForCheck(in chan<- fileHash) {
+	defer close(in)
+
+	var checkFile io.Reader = checkStdin
+	if checkFileName() != "-" {
+		f, err := os.Open(checkFileName())
+		if err != nil {
+			in <- fileHash{line: -1, err: err}
+			return
+		}
+		defer f.Close()
+		checkFile = f
+	}
+
+	if *fManifestDigest {
+		//the whole file is needed before any of it can be trusted
+		data, err := ioutil.ReadAll(checkFile)
+		if err != nil {
+			in <- fileHash{line: -1, err: newHashError("read", checkFileName(), err)}
+			return
+		}
+		if err := checkManifestDigest(data); err != nil {
+			in <- fileHash{line: -1, err: newHashError("check", checkFileName(), err)}
+			return
+		}
+		checkFile = bytes.NewReader(data)
+	}
+
+	if *fJSONManifest {
+		readJSONManifest(in, checkFile)
+		return
+	}
+
+	var ending = &lastByteReader{r: checkFile, last: -1}
+	s := bufio.NewScanner(ending)
+	if *fCDC {
+		readChunkManifest(in, s)
+	} else {
+		for line := 0; s.Scan(); line++ {
+			if strings.HasPrefix(s.Text(), manifestDigestPrefix) && !*fCompat || line < headerLines {
+				continue
+			}
+			algo, expected, name, ok := parseCheckLine(s.Text())
+			var mode os.FileMode
+			var recorded string
+			if ok && *fVerifyPermissions {
+				mode, name, ok = splitMode(name)
+			}
+			if ok && *fVerifyOwner {
+				recorded, name, ok = splitOwner(name)
+			}
+			if !ok {
+				in <- fileHash{line: line, err: newHashError("decode", checkFileName(), fmt.Errorf("line %d is not of the form: hash value filename", line+1))}
+				continue
+			}
+			if inferAlgo {
+				compatAlgo.CompareAndSwap(nil, algo)
+			}
+			file := checkListed(algo, expected, name, mode, line)
+			file.expectedOwner = recorded
+			in <- file
+		}
+	}
+	if err := s.Err(); err != nil {
+		in <- fileHash{line: -1, err: newHashError("read", checkFileName(), err)}
+	} else if *fStrictNewline && ending.last >= 0 && ending.last != '\n' && !*fStatus {
+		fmt.Fprintf(os.Stderr, "warning: %s doesn't end with a newline, it may have been cut short\n", checkFileName())
+	}
+}
+
+//Where entry line is in the check file, for messages
+func listedAt(line int) string {
+	if *fPairs {
+		return fmt.Sprintf("argument %d", line+1)
+	}
+	if *fJSONManifest {
+		return fmt.Sprintf("entry %d of %s", line+1, checkFileName())
+	}
+	return fmt.Sprintf("line %d of %s", line+1, checkFileName())
+}
+
+//Check one entry of the check file and open the file it names, or say what
+//is wrong with it
+func checkListed(algo, expected, name string, mode os.FileMode, line int) fileHash {
+	if err := requireAlgo(algo, name, line); err != nil {
+		return fileHash{fileName: &name, line: line, err: err}
+	}
+	if algo == "crc32" && *fCRCFormat != "hex" {
+		if expected = crcHex(expected); expected == "" {
+			return fileHash{line: line, err: newHashError("decode", checkFileName(), fmt.Errorf("%s does not have a crc32 in -crc-format %s", listedAt(line), *fCRCFormat))}
+		}
+	}
+	if reason := suspiciousHash(algo, expected); reason != "" {
+		err := newHashError("check", name, fmt.Errorf("%s has a suspicious hash: %s", listedAt(line), reason))
+		if *fStrictHashes {
+			return fileHash{fileName: &name, line: line, err: err}
+		}
+		if !*fStatus {
+			fmt.Fprintln(os.Stderr, "warning: "+err.Error())
+		}
+	}
+	return openListed(fileHash{expectedHashType: &algo, expecteHash: &expected, expectedMode: mode, line: line}, name)
+}
+
+//With -require-algo-match, refuse check file lines that aren't for -h
+func requireAlgo(algo, name string, line int) error {
+	if !*fRequireAlgoMatch || algo == *fHash {
+		return nil
+	}
+	return newHashError("check", name, fmt.Errorf("%s uses %s, not %s", listedAt(line), algo, *fHash))
+}
+
+//Why an expected hash from the check file can't be a real hash of algo, or
+//"" when it looks fine. All zeros is the usual sign of a broken or tampered
+//manifest.
+func suspiciousHash(algo, expected string) string {
+	if expected == "" {
+		return "it is empty"
+	}
+	if _, err := hex.DecodeString(expected); err != nil {
+		return "it is not hex"
+	}
+	if strings.Trim(expected, "0") == "" {
+		return "it is all zeros"
+	}
+	if base, _, ok := segmented(algo); ok {
+		algo = base
+	}
+	algo, _ = gitBlob(algo)
+	algo, _ = withMetadata(algo)
+	if h, err := hashes.NewHasher(algo); err == nil && len(expected) != 2*h.Size() {
+		return fmt.Sprintf("%s hashes are %d hex digits, not %d", algo, 2*h.Size(), len(expected))
+	}
+	return ""
+}
+
+//Open the file a check file line names, filling in file
+func openListed(file fileHash, name string) fileHash {
+	var algos = []string{*file.expectedHashType}
+	sums, info, cached := cachedSums(algos, name)
+	if cached {
+		file.fileName, file.hash, file.algos, file.sums, file.mode = &name, sums[0], algos, sums, permissions(name)
+		return file
+	}
+	file.cacheInfo = info
+
+	stream, size, err := openInput(name)
+	if err != nil && *fIgnoreCase && os.IsNotExist(err) {
+		if actual, findErr := findIgnoreCase(name); findErr == nil {
+			name = actual
+			stream, size, err = openInput(name)
+		}
+	}
+
+	file.fileName = &name
+	if err != nil {
+		file.err = newHashError("open", name, err)
+	} else if fi, statErr := os.Stat(name); statErr == nil && fi.IsDir() && !*fStructure && !*fGoSum && !*fCompat {
+		//something else replaced the file that was hashed
+		stream.Close()
+		releaseOpen()
+		file.err = newHashError("check", name, errors.New("expected file, found directory"))
+	} else {
+		file.r, file.size, file.mode = stream, size, permissions(name)
+		prog.grow(size)
+	}
+	return file
+}
+
+//Rewrite the check file with the new hashes in changed, keyed by line number.
+//Lines of files that could not be opened are dropped when -fix-prune is set.
+func fixCheckFile(name string, changed map[int]string, missing map[int]bool) error {
+	if name == "-" {
+		return errors.New("-fix cannot rewrite a check file read from stdin")
+	}
+
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	var fixed []byte
+	s := bufio.NewScanner(strings.NewReader(string(data)))
+	for line := 0; s.Scan(); line++ {
+		if *fFixPrune && missing[line] {
+			continue
+		}
+		var text = s.Text()
+		if hash, ok := changed[line]; ok {
+			text = replaceCheckHash(text, hash)
+		} else if strings.HasPrefix(text, manifestDigestPrefix) {
+			//cover the fixed hashes instead
+			sums, _, _ := listedSums(fixed)
+			var algo = strings.SplitN(strings.TrimPrefix(text, manifestDigestPrefix), " ", 2)[0]
+			if digestLine, err := manifestDigestLine(algo, sums); err == nil {
+				text = digestLine
+			}
+		}
+		fixed = append(fixed, text+"\n"...)
+	}
+	if err := s.Err(); err != nil {
+		//writing what was read would lose the rest
+		return newHashError("read", name, err)
+	}
+
+	fi, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(name, finalNewline(fixed), fi.Mode().Perm())
+}
+
+//How bad a check result is, for -syslog
+type resultLevel int
+
+const (
+	resultOK     resultLevel = iota //the file matched
+	resultFailed                    //the file did not match
+	resultError                     //the file could not be checked
+)
+
+//Print the result of each check as it arrives and return the exit status
+func reportCheckResults(out <-chan fileHash) int {
+	var checked, malformed, unreadable, mismatched, modeChanged, other int
+	changed := make(map[int]string)
+	missing := make(map[int]bool)
+	//with -no-extra, the cleaned names of every file the check file lists
+	listed := make(map[string]bool)
+	report := func(curResult fileHash) {
+		if *fNoExtra != "" && curResult.fileName != nil {
+			listed[filepath.Clean(*curResult.fileName)] = true
+		}
+		if curResult.err != nil {
+			e, ok := curResult.err.(*hashError)
+			if !ok || curResult.line < 0 {
+				//not about any one file, e.g. the check file could not be read
+				printError(curResult.err)
+				logResult(resultError, curResult.err.Error())
+				other++
+				return
+			}
+
+			if e.op == "decode" {
+				malformed++
+				summary.Failed++
+				if !*fCompat {
+					printError(e)
+				}
+				logResult(resultError, e.Error())
+				return
+			}
+
+			printError(e)
+			logResult(resultError, e.Error())
+			checked++
+			if e.op == "open" {
+				missing[curResult.line] = true
+				if *fFailOnMissing || *fCompat {
+					summary.Failed++
+				} else {
+					summary.Skipped++
+				}
+			} else {
+				unreadable++
+				summary.Failed++
+			}
+			if *fCompat && !*fStatus {
+				prefix, name := compatEscape(curResult.displayName(), true)
+				fmt.Printf("%s%s: FAILED open or read\n", prefix, name)
+			}
+			return
+		}
+
+		checked++
+		var computed = fmt.Sprintf("%0x", curResult.hash)
+		var matched bool
+		if *fCDC {
+			matched = checkChunks(curResult)
+		} else {
+			matched = computed == *curResult.expecteHash
+		}
+		summary.Bytes += curResult.bytes
+		if *fVerifyPermissions && curResult.mode != curResult.expectedMode {
+			if !*fStatus {
+				fmt.Fprintf(os.Stderr, "%s: permissions are %04o, expected %04o\n", *curResult.fileName, curResult.mode, curResult.expectedMode)
+			}
+			if *fPermissionsFatal {
+				modeChanged++
+			}
+		}
+		if *fVerifyOwner && curResult.fileName != nil {
+			if got := ownership(*curResult.fileName); !sameOwner(got, curResult.expectedOwner) {
+				if !*fStatus {
+					fmt.Fprintf(os.Stderr, "%s: owner is %s, expected %s\n", *curResult.fileName, got, curResult.expectedOwner)
+				}
+				if *fPermissionsFatal {
+					modeChanged++
+				}
+			}
+		}
+		if matched {
+			summary.Succeeded++
+		} else {
+			mismatched++
+			summary.Failed++
+			changed[curResult.line] = hashText(*curResult.expectedHashType, curResult.hash)
+			if *fDiffBytes != "" && !*fStatus {
+				if hint := diffBytesHint(*curResult.fileName); hint != "" {
+					fmt.Fprintln(os.Stderr, hint)
+				}
+			}
+			if *fEOLHint && !*fCDC && !*fStatus {
+				if hint := eolHint(curResult); hint != "" {
+					fmt.Fprintln(os.Stderr, hint)
+				}
+			}
+		}
+		summary.addAlgorithm(*curResult.expectedHashType)
+		if matched {
+			logResult(resultOK, *curResult.fileName+": OK")
+		} else {
+			logResult(resultFailed, *curResult.fileName+": FAILED")
+		}
+
+		if *fStatus {
+			//the exit status says it all
+		} else if *fCompat {
+			var status = "OK"
+			if !matched {
+				status = "FAILED"
+			}
+			prefix, name := compatEscape(*curResult.fileName, true)
+			fmt.Printf("%s%s: %s\n", prefix, name, status)
+		} else {
+			fmt.Printf("%s %t\n", *curResult.fileName, matched)
+		}
+	}
+
+	var extra int
+	for curResult := range out {
+		report(curResult)
+		if *fFirstMismatch && (mismatched+malformed+unreadable+modeChanged+other > 0 || *fFailOnMissing && len(missing) > 0) {
+			//main exits once this returns, taking the rest of the pipeline with it
+			break
+		}
+	}
+	if *fNoExtra != "" && !(*fFirstMismatch && mismatched+malformed+unreadable+modeChanged+other > 0) {
+		files, err := extraFiles(*fNoExtra, listed)
+		if err != nil {
+			printError(err)
+			other++
+		}
+		for _, name := range files {
+			if !*fStatus {
+				fmt.Fprintf(os.Stderr, "%s: not in %s\n", name, checkFileName())
+			}
+			logResult(resultFailed, name+": not in the check file")
+			summary.Failed++
+			extra++
+		}
+	}
+
+	//only a check file read cleanly to its end is rewritten
+	if *fFix && other == 0 && (len(changed) > 0 || *fFixPrune && len(missing) > 0) {
+		if err := fixCheckFile(checkFileName(), changed, missing); err != nil {
+			printError(err)
+			other++
+		}
+	}
+
+	if *fCompat && other == 0 {
+		if status := compatCheckSummary(checked, malformed, unreadable+len(missing), mismatched); extra == 0 {
+			return status
+		}
+		return 1
+	}
+
+	if other > 0 {
+		return 1
+	}
+	if checked+malformed == 0 {
+		var name = checkFileName()
+		if name == "-" {
+			name = "standard input"
+		}
+		printError(newHashError("check", name, errors.New("no hash lines found, nothing was verified")))
+		return 1
+	}
+
+	var failed = mismatched + malformed + unreadable + modeChanged + extra
+	if *fFailOnMissing && len(missing) > 0 {
+		if !*fStatus {
+			fmt.Fprintf(os.Stderr, "%d listed files could not be read\n", len(missing))
+		}
+		failed += len(missing)
+	}
+	if failed > 0 {
+		if !*fStatus {
+			fmt.Fprintf(os.Stderr, "%d files failed verification\n", failed)
+		}
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,133 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+//A hash listed in the -compare-to manifest
+type reference struct {
+	algo string
+	hash string
+}
+
+//The -compare-to manifest: the files it lists in order, and their hashes
+var referenceNames []string
+var references map[string]reference
+
+//Read the -compare-to manifest
+func readReferences(manifest string) error {
+	f, err := os.Open(manifest)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	references = make(map[string]reference)
+	s := bufio.NewScanner(f)
+	for line := 0; s.Scan(); line++ {
+		algo, hash, name, ok := parseCheckLine(s.Text())
+		if ok && *fVerifyPermissions {
+			_, name, ok = splitMode(name)
+		}
+		if ok && *fVerifyOwner {
+			_, name, ok = splitOwner(name)
+		}
+		if !ok {
+			return newHashError("decode", manifest, fmt.Errorf("line %d is not of the form: hash value filename", line+1))
+		}
+		if _, dup := references[name]; !dup {
+			referenceNames = append(referenceNames, name)
+		}
+		references[name] = reference{algo, hash}
+	}
+	if err := s.Err(); err != nil {
+		return newHashError("read", manifest, err)
+	}
+	return nil
+}
+
+//Print how each file hashed compares to the -compare-to manifest, one line
+//each as one of
+//
+//	same <name>
+//	changed <name>
+//	new <name>
+//	gone <name>
+//
+//new files are not in the manifest, and gone ones are in it but no longer
+//exist. Returns 1 unless every file is the same.
+func reportComparison(out <-chan fileHash) int {
+	var status int
+	var seen = make(map[string]bool)
+	for curResult := range out {
+		if curResult.err != nil {
+			e, ok := curResult.err.(*hashError)
+			if ok && e.op == "open" && os.IsNotExist(e.err) && curResult.fileName != nil {
+				if _, listed := references[*curResult.fileName]; listed {
+					seen[*curResult.fileName] = true
+					fmt.Printf("gone %s\n", *curResult.fileName)
+					summary.Skipped++
+					status = 1
+					continue
+				}
+			}
+			printError(curResult.err)
+			summary.Failed++
+			status = 1
+			continue
+		}
+
+		var name = *curResult.fileName
+		seen[name] = true
+		summary.add(curResult)
+		ref, listed := references[name]
+		switch {
+		case !listed:
+			fmt.Printf("new %s\n", name)
+			status = 1
+		case hashText(*curResult.expectedHashType, curResult.hash) != ref.hash:
+			fmt.Printf("changed %s\n", name)
+			status = 1
+		default:
+			fmt.Printf("same %s\n", name)
+		}
+	}
+
+	for _, name := range referenceNames {
+		if !seen[name] {
+			if _, err := os.Lstat(name); os.IsNotExist(err) {
+				fmt.Printf("gone %s\n", name)
+				status = 1
+			}
+		}
+	}
+	return status
+}
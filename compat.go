@@ -0,0 +1,148 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+//Output and parsing that matches md5sum, sha256sum and friends for -compat.
+
+//The algorithm of the first line of the check file, when inferAlgo told it
+//by the length of the hash rather than -h
+var compatAlgo atomic.Value
+
+//Name of the coreutils program being imitated, e.g. sha256sum
+func compatName() string {
+	if algo, ok := compatAlgo.Load().(string); ok {
+		return algo + "sum"
+	}
+	if *fHash == "cksum" {
+		return "cksum"
+	}
+	return *fHash + "sum"
+}
+
+//Format err the way coreutils does: "file: No such file or directory"
+func compatError(err error) string {
+	var path string
+	switch e := err.(type) {
+	case *hashError:
+		path, err = e.path, e.err
+	case *os.PathError:
+		path, err = e.Path, e.Err
+	default:
+		return err.Error()
+	}
+
+	var msg = err.Error()
+	r, size := utf8.DecodeRuneInString(msg)
+	return path + ": " + string(unicode.ToUpper(r)) + msg[size:]
+}
+
+var compatEscaper = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\r", "\\r")
+var compatUnescaper = strings.NewReplacer("\\\\", "\\", "\\n", "\n", "\\r", "\r")
+
+//Escape a file name like coreutils. The returned prefix is "\" when the
+//name needed escaping and must start the output line. Check results only
+//escape names containing line breaks.
+func compatEscape(name string, check bool) (prefix, escaped string) {
+	var special = "\\\n\r"
+	if check {
+		special = "\n\r"
+	}
+	if !strings.ContainsAny(name, special) {
+		return "", name
+	}
+	return "\\", compatEscaper.Replace(name)
+}
+
+//Parse a "hash  file" or "hash *file" line written by coreutils
+func parseCompatLine(text string) (hash, name string, ok bool) {
+	var escaped = strings.HasPrefix(text, "\\")
+	if escaped {
+		text = text[1:]
+	}
+
+	i := strings.Index(text, " ")
+	if i <= 0 || i+2 > len(text) || (text[i+1] != ' ' && text[i+1] != '*') {
+		return "", "", false
+	}
+	hash, name = text[:i], text[i+2:]
+	if escaped {
+		name = compatUnescaper.Replace(name)
+	}
+	return hash, name, name != ""
+}
+
+//Print the warnings coreutils prints after checking and return its exit status
+func compatCheckSummary(checked, malformed, unreadable, mismatched int) int {
+	if checked == 0 {
+		var name = checkFileName()
+		if name == "-" {
+			name = "standard input"
+		}
+		if !*fSilent {
+			fmt.Fprintf(os.Stderr, "%s: %s: no properly formatted checksum lines found\n", compatName(), name)
+		}
+		return 1
+	}
+	if *fStatus {
+		return compatStatus(unreadable, mismatched)
+	}
+
+	if malformed == 1 {
+		fmt.Fprintf(os.Stderr, "%s: WARNING: 1 line is improperly formatted\n", compatName())
+	} else if malformed > 1 {
+		fmt.Fprintf(os.Stderr, "%s: WARNING: %d lines are improperly formatted\n", compatName(), malformed)
+	}
+	if unreadable == 1 {
+		fmt.Fprintf(os.Stderr, "%s: WARNING: 1 listed file could not be read\n", compatName())
+	} else if unreadable > 1 {
+		fmt.Fprintf(os.Stderr, "%s: WARNING: %d listed files could not be read\n", compatName(), unreadable)
+	}
+	if mismatched == 1 {
+		fmt.Fprintf(os.Stderr, "%s: WARNING: 1 computed checksum did NOT match\n", compatName())
+	} else if mismatched > 1 {
+		fmt.Fprintf(os.Stderr, "%s: WARNING: %d computed checksums did NOT match\n", compatName(), mismatched)
+	}
+
+	return compatStatus(unreadable, mismatched)
+}
+
+//The exit status coreutils gives after checking
+func compatStatus(unreadable, mismatched int) int {
+	if unreadable > 0 || mismatched > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,83 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+//Count the files openFilesForHashing sends and their bytes for -count-only,
+//without hashing them, and print the totals:
+//
+//	<files> files, <bytes> bytes
+//
+//Sizes come from the filesystem; only pipes and the like are read through.
+//Returns the exit status.
+func countOnly(in <-chan fileHash) int {
+	status := 0
+	var files, bytes int64
+	for file := range in {
+		if file.err != nil {
+			printError(file.err)
+			status = 1
+			continue
+		}
+		var size = file.size
+		if file.r == nil {
+			//taken from -cache or -only-changed, and never opened
+			fi, err := os.Stat(*file.fileName)
+			if err != nil {
+				printError(newHashError("open", *file.fileName, err))
+				status = 1
+				continue
+			}
+			size = fi.Size()
+		} else {
+			var err error
+			if size < 0 {
+				size, err = io.Copy(ioutil.Discard, file.r)
+				if err != nil {
+					err = readError(file.displayName(), err)
+				}
+			}
+			file.r.Close()
+			releaseOpen()
+			if err != nil {
+				printError(err)
+				status = 1
+				continue
+			}
+		}
+		files++
+		bytes += size
+	}
+	fmt.Printf("%d files, %d bytes\n", files, bytes)
+	return status
+}
@@ -0,0 +1,90 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
+//How crc32 values are written, for -crc-format. crc32 is kept as its 4 big
+//endian bytes everywhere else, which is what hex shows.
+var crcFormats = map[string]bool{
+	"hex":    true, //big-endian hex, as zip and sfv files have it
+	"le":     true, //little-endian hex, the bytes as stored by some tools
+	"dec":    true, //unsigned decimal
+	"signed": true, //signed 32-bit decimal, as Java's int holds it
+}
+
+//The text of algo's hash sum in hash lines
+func hashText(algo string, sum []byte) string {
+	if algo != "crc32" || *fCRCFormat == "hex" || len(sum) != 4 {
+		return fmt.Sprintf("%0x", sum)
+	}
+	var crc = binary.BigEndian.Uint32(sum)
+	switch *fCRCFormat {
+	case "le":
+		var le [4]byte
+		binary.LittleEndian.PutUint32(le[:], crc)
+		return fmt.Sprintf("%0x", le)
+	case "dec":
+		return strconv.FormatUint(uint64(crc), 10)
+	}
+	return strconv.FormatInt(int64(int32(crc)), 10)
+}
+
+//The hex of a crc32 written in -crc-format, the reverse of hashText, or ""
+//when text isn't one
+func crcHex(text string) string {
+	var crc uint32
+	switch *fCRCFormat {
+	case "le":
+		v, err := strconv.ParseUint(text, 16, 32)
+		if err != nil || len(text) != 8 {
+			return ""
+		}
+		var le [4]byte
+		binary.BigEndian.PutUint32(le[:], uint32(v))
+		crc = binary.LittleEndian.Uint32(le[:])
+	case "dec":
+		v, err := strconv.ParseUint(text, 10, 32)
+		if err != nil {
+			return ""
+		}
+		crc = uint32(v)
+	case "signed":
+		v, err := strconv.ParseInt(text, 10, 32)
+		if err != nil {
+			return ""
+		}
+		crc = uint32(int32(v))
+	default:
+		return text
+	}
+	return fmt.Sprintf("%08x", crc)
+}
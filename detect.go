@@ -0,0 +1,95 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+//Every algorithm -detect tries, the usual ones first, as the first match is
+//the one reported
+var detectOrder = []string{"sha256", "sha1", "md5", "sha512", "sha384", "sha224", "blake3", "crc32", "btv2"}
+
+//Work out which algorithm gave expected, a hash in hex, for each FILE. Only
+//algorithms with hashes of the same length are tried, all of them in one
+//read of the file. The first that matches is printed as
+//
+//	<algo> <hash> <name>
+//
+//or with -detect-all every one that does. Returns the exit status: 0 if
+//every FILE matched something.
+func detect(expected string, names []string) int {
+	expected = strings.ToLower(expected)
+	var candidates []string
+	for _, algo := range detectOrder {
+		if isHashOf(algo, expected) {
+			candidates = append(candidates, algo)
+		}
+	}
+	if len(candidates) == 0 {
+		printError(fmt.Errorf("%s is not a hash of any algorithm gohash knows", expected))
+		return 1
+	}
+	var algos = strings.Join(candidates, ",")
+
+	status := 0
+	for _, name := range names {
+		var name = name
+		f, err := os.Open(name)
+		if err != nil {
+			printError(newHashError("open", name, err))
+			status = 1
+			continue
+		}
+		tried, sums, err := digest(fileHash{fileName: &name, r: f, expectedHashType: &algos})
+		f.Close()
+		if err != nil {
+			printError(err)
+			status = 1
+			continue
+		}
+
+		var matched bool
+		for i, algo := range tried {
+			if fmt.Sprintf("%0x", sums[i]) != expected {
+				continue
+			}
+			fmt.Printf("%s %s %s\n", algo, expected, name)
+			matched = true
+			if !*fDetectAll {
+				break
+			}
+		}
+		if !matched {
+			fmt.Fprintf(os.Stderr, "%s: no %s hash matches\n", name, strings.Join(candidates, ", "))
+			status = 1
+		}
+	}
+	return status
+}
@@ -0,0 +1,122 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/dietsche/gohash/hashes"
+)
+
+//Files larger than this are not compared by -diff-bytes
+const diffBytesLimit = 16 << 20
+
+//Explain why a small file failed verification by comparing it with the copy
+//of the same name under the -diff-bytes directory, or at least by its size.
+func diffBytesHint(name string) string {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return err.Error()
+	}
+	if fi.Size() > diffBytesLimit {
+		return ""
+	}
+
+	current, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err.Error()
+	}
+	reference, err := ioutil.ReadFile(filepath.Join(*fDiffBytes, name))
+	if os.IsNotExist(err) {
+		return fmt.Sprintf("%s: %d bytes, no reference copy to compare with", name, len(current))
+	} else if err != nil {
+		return err.Error()
+	}
+
+	var i int
+	for i < len(current) && i < len(reference) && current[i] == reference[i] {
+		i++
+	}
+	switch {
+	case bytes.Equal(current, reference):
+		return fmt.Sprintf("%s: same as the reference copy, the expected hash may be wrong", name)
+	case len(current) != len(reference):
+		return fmt.Sprintf("%s: %d bytes but the reference copy is %d bytes, first difference at byte %d", name, len(current), len(reference), i)
+	}
+	return fmt.Sprintf("%s: first difference from the reference copy at byte %d", name, i)
+}
+
+//For -eol-hint, tell whether a text file that failed verification would
+//have matched with its line endings converted, the usual sign of a file
+//copied between Windows and Unix. Binary files, those containing a NUL
+//byte, and files over diffBytesLimit are left alone.
+func eolHint(file fileHash) string {
+	var name = *file.fileName
+	fi, err := os.Stat(name)
+	if err != nil || fi.Size() > diffBytesLimit {
+		return ""
+	}
+	if _, _, ok := segmented(*file.expectedHashType); ok {
+		return ""
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil || bytes.IndexByte(data, 0) >= 0 {
+		return ""
+	}
+
+	var unix = bytes.Replace(data, []byte("\r\n"), []byte("\n"), -1)
+	var windows = bytes.Replace(unix, []byte("\n"), []byte("\r\n"), -1)
+	for _, converted := range []struct {
+		endings string
+		data    []byte
+	}{{"Unix (LF)", unix}, {"Windows (CRLF)", windows}} {
+		if bytes.Equal(converted.data, data) {
+			continue
+		}
+		algo, _ := gitBlob(*file.expectedHashType)
+		algo, _ = withMetadata(algo)
+		h, err := hashes.Borrow(algo)
+		if err != nil {
+			return ""
+		}
+		prefix, err := framing(fileHash{fileName: file.fileName, expectedHashType: file.expectedHashType, size: int64(len(converted.data))})
+		if err == nil {
+			h.Write(prefix)
+			h.Write(converted.data)
+		}
+		var sum = fmt.Sprintf("%0x", h.Sum(nil))
+		hashes.Return(algo, h)
+		if err == nil && sum == *file.expecteHash {
+			return fmt.Sprintf("%s: would match with %s line endings", name, converted.endings)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,110 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//The -dupe-index: for each hash seen, in this run or an earlier one, the
+//first file that had it, keyed by algorithm and hash in hex. It is saved as
+//a manifest, one line a hash:
+//
+//	<algo> <hash> <name>
+var dupes = struct {
+	first map[string]string
+	dirty bool
+}{first: make(map[string]string)}
+
+//Read the -dupe-index file; one that doesn't exist yet is an empty index
+func loadDupeIndex(name string) error {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		var splits = strings.SplitN(s.Text(), " ", 3)
+		if len(splits) < 3 || splits[2] == "" {
+			return newHashError("decode", name, fmt.Errorf("line %d is not of the form: hash value filename", line))
+		}
+		dupes.first[splits[0]+" "+splits[1]] = splits[2]
+	}
+	if err := s.Err(); err != nil {
+		return newHashError("read", name, err)
+	}
+	return nil
+}
+
+//The file seen before name with the same algo hash, if there is one. When
+//there isn't, name is recorded as the first to have it. A file that was
+//first but is gone now is replaced rather than reported. Names are kept
+//absolute, so the index still finds them from another directory.
+func dupeOf(algo, hash, name string) (string, bool) {
+	if abs, err := filepath.Abs(name); err == nil {
+		name = abs
+	}
+	var key = algo + " " + hash
+	if first, ok := dupes.first[key]; ok && first != name {
+		if _, err := os.Stat(first); err == nil {
+			return first, true
+		}
+	} else if ok {
+		return "", false
+	}
+	dupes.first[key] = name
+	dupes.dirty = true
+	return "", false
+}
+
+//Write the -dupe-index back, sorted, when anything was added to it
+func saveDupeIndex(name string) error {
+	if !dupes.dirty {
+		return nil
+	}
+	var keys []string
+	for key := range dupes.first {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "%s %s\n", key, dupes.first[key])
+	}
+	return writeFileAtomic(name, buf.Bytes(), 0644)
+}
@@ -0,0 +1,326 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//Slots for inputs that are open, when -max-open limits them
+var openSlots chan struct{}
+
+//Open an input for hashing: the file itself, its structure with -structure, or
+//its go.sum summary with -go-sum.
+//With -max-open this waits for a slot, which releaseOpen gives back once the
+//input is closed.
+func openInput(name string) (r io.ReadCloser, size int64, err error) {
+	acquireOpen()
+	defer func() {
+		if err != nil {
+			releaseOpen()
+		}
+	}()
+
+	if *fStructure {
+		r, err = structureReader(name)
+		return r, -1, err
+	}
+	if *fGoSum {
+		r, err = goSumReader(name)
+		return r, -1, err
+	}
+	if strings.HasPrefix(name, tcpPrefix) {
+		r, err = dialStream(name)
+		return r, -1, err
+	}
+	return openFile(name)
+}
+
+//Wait for a slot for an input about to be opened
+func acquireOpen() {
+	if openSlots != nil {
+		openSlots <- struct{}{}
+	}
+}
+
+//Give back the slot of an input once it is closed
+func releaseOpen() {
+	if openSlots != nil {
+		<-openSlots
+	}
+}
+
+//Permission bits of the named file for -verify-permissions
+func permissions(name string) os.FileMode {
+	if !*fVerifyPermissions {
+		return 0
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		return 0
+	}
+	return fi.Mode().Perm()
+}
+
+//For -warn-symlink, say when name is a symbolic link and what it points to.
+//It is still hashed, by what it points to.
+func warnSymlink(name string) {
+	fi, err := os.Lstat(name)
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return
+	}
+	target, err := os.Readlink(name)
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "warning: %s is a symbolic link to %s\n", name, target)
+}
+
+//Owner of the named file for -verify-owner, or - when it has none that can
+//be told
+func ownership(name string) string {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return "-"
+	}
+	return owner(fi)
+}
+
+//What -verify-permissions and -verify-owner record of file between its hash
+//and its name, each column followed by a space
+func recordedColumns(file fileHash) string {
+	var columns string
+	if *fVerifyPermissions {
+		columns += fmt.Sprintf("%04o ", file.mode)
+	}
+	if *fVerifyOwner {
+		columns += ownership(file.displayName()) + " "
+	}
+	return columns
+}
+
+//Open a file for hashing and find out how big it is
+func openFile(name string) (*os.File, int64, error) {
+	stream, err := os.Open(name)
+	if err != nil {
+		return nil, -1, err
+	}
+	size, err := fileSize(stream)
+	if err != nil {
+		stream.Close()
+		return nil, -1, err
+	}
+	return stream, size, nil
+}
+
+//Reads the named files one after another like cat, opening each file only
+//once the one before it is used up
+type concatReader struct {
+	names []string
+	cur   *os.File
+}
+
+func (c *concatReader) Read(p []byte) (int, error) {
+	for {
+		if c.cur == nil {
+			if len(c.names) == 0 {
+				return 0, io.EOF
+			}
+			f, err := os.Open(c.names[0])
+			if err != nil {
+				return 0, newHashError("open", c.names[0], err)
+			}
+			c.cur, c.names = f, c.names[1:]
+		}
+
+		n, err := c.cur.Read(p)
+		if err == io.EOF {
+			c.cur.Close()
+			c.cur = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (c *concatReader) Close() error {
+	if c.cur == nil {
+		return nil
+	}
+	return c.cur.Close()
+}
+
+//Counts the bytes read through it
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+//The open file descriptor fd, for -out-fd and -progress-fd
+func openFD(fd int, name string) (*os.File, error) {
+	f := os.NewFile(uintptr(fd), name)
+	if f == nil {
+		return nil, fmt.Errorf("file descriptor %d is not valid", fd)
+	}
+	if _, err := f.Stat(); err != nil {
+		return nil, fmt.Errorf("file descriptor %d is not open", fd)
+	}
+	return f, nil
+}
+
+//Write data to a temporary file next to name and rename it into place, so
+//readers see either the old or the new content but never a partial write.
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+//Write the -o manifest data to name, unless name already holds exactly that,
+//so an unchanged manifest keeps its modification time
+func writeManifest(name string, data []byte) error {
+	data = finalNewline(data)
+	var perm os.FileMode = 0644
+	if fi, err := os.Stat(name); err == nil {
+		perm = fi.Mode().Perm()
+		if old, err := ioutil.ReadFile(name); err == nil && bytes.Equal(old, data) {
+			return nil
+		}
+	}
+	return writeFileAtomic(name, data, perm)
+}
+
+//Write the -split-by-algo manifests to dir/sums.ALGO, each the way -o would
+func writeSplit(dir string, split map[string]*bytes.Buffer) error {
+	if len(split) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for algo, manifest := range split {
+		if err := writeManifest(filepath.Join(dir, "sums."+algo), manifest.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Sidecar names -raw-dir has handed out, so files with the same base name
+//don't overwrite each other's
+var rawNames = make(map[string]bool)
+
+//Where -raw-dir writes the raw algo hash of the file name: DIR/<base
+//name>.<algo>, the base name getting .2, .3 and so on when that is taken,
+//or with -raw-dir-mirror the file's whole path under DIR.
+func rawSidecar(name, algo string) string {
+	var ext = "." + strings.Replace(algo, "/", "-", -1)
+	if *fRawDirMirror {
+		name = strings.TrimPrefix(name, filepath.VolumeName(name))
+		return filepath.Join(*fRawDir, filepath.Clean(string(filepath.Separator)+name)+ext)
+	}
+
+	var base = filepath.Base(name)
+	if base == "-" {
+		base = "stdin"
+	}
+	var sidecar = filepath.Join(*fRawDir, base+ext)
+	for n := 2; rawNames[sidecar]; n++ {
+		sidecar = filepath.Join(*fRawDir, fmt.Sprintf("%s.%d%s", base, n, ext))
+	}
+	rawNames[sidecar] = true
+	return sidecar
+}
+
+//Write the raw hash of one file for -raw-dir
+func writeSidecar(name, algo string, sum []byte) error {
+	var sidecar = rawSidecar(name, algo)
+	if err := os.MkdirAll(filepath.Dir(sidecar), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(sidecar, sum, 0644)
+}
+
+//Find the file on disk whose path matches name when case is ignored, one
+//path element at a time. Exact matches win.
+func findIgnoreCase(name string) (string, error) {
+	if _, err := os.Lstat(name); err == nil {
+		return name, nil
+	}
+
+	dir, base := filepath.Dir(name), filepath.Base(name)
+	if dir != name && dir != "." {
+		var err error
+		if dir, err = findIgnoreCase(dir); err != nil {
+			return "", err
+		}
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if strings.EqualFold(e.Name(), base) {
+			return filepath.Join(dir, e.Name()), nil
+		}
+	}
+	return "", &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+}
@@ -0,0 +1,66 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+//Bytes of the hash a -fingerprint is made from
+const fingerprintBytes = 8
+
+//A -fingerprint of sum: its first fingerprintBytes in Bubble Babble
+func fingerprint(sum []byte) string {
+	if len(sum) > fingerprintBytes {
+		sum = sum[:fingerprintBytes]
+	}
+	return bubbleBabble(sum)
+}
+
+//Encode data in Bubble Babble, the pronounceable encoding ssh-keygen -B
+//uses, for -fingerprint. Each pair of bytes becomes five letters
+//alternating vowels and consonants, with a checksum carried along in seed,
+//and the result starts and ends with x: the first 8 bytes of a hash come
+//out like xesef-disof-gytuf-katof-moxex for "12345678".
+func bubbleBabble(data []byte) string {
+	const vowels = "aeiouy"
+	const consonants = "bcdfghklmnprstvzx"
+
+	var out = []byte{'x'}
+	var seed = 1
+	var rounds = len(data)/2 + 1
+	for i := 0; i < rounds; i++ {
+		if i+1 < rounds || len(data)%2 != 0 {
+			b1 := int(data[2*i])
+			out = append(out, vowels[((b1>>6&3)+seed)%6], consonants[b1>>2&15], vowels[((b1&3)+seed/6)%6])
+			if i+1 < rounds {
+				b2 := int(data[2*i+1])
+				out = append(out, consonants[b2>>4&15], '-', consonants[b2&15])
+				seed = (seed*5 + b1*7 + b2) % 36
+			}
+		} else {
+			out = append(out, vowels[seed%6], consonants[16], vowels[seed/6])
+		}
+	}
+	return string(append(out, 'x'))
+}
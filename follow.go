@@ -0,0 +1,99 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/dietsche/gohash/hashes"
+)
+
+//Hash name as it grows for -follow, like tail -f. Whenever more of the file
+//has been read, print the hash of everything up to the current end:
+//
+//	follow <algo> <bytes> <hash> <name>
+//
+//then wait -follow-interval and read on, until interrupted. A line only
+//covers what had been written when it was read, so with writers still
+//appending, which lines appear depends on timing. Returns the exit status.
+func follow(name string) int {
+	f, err := os.Open(name)
+	if err != nil {
+		printError(newHashError("open", name, err))
+		return 1
+	}
+	defer f.Close()
+
+	h, err := hashes.Borrow(*fHash)
+	if err != nil {
+		printError(newHashError("hash", name, err))
+		return 1
+	}
+	defer hashes.Return(*fHash, h)
+
+	var r io.Reader = f
+	if throttle != nil {
+		r = &throttledReader{f, throttle}
+	}
+	if ratePerFile > 0 {
+		r = &throttledReader{ioutil.NopCloser(r), newLimiter(ratePerFile)}
+	}
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+
+	var offset int64 = -1
+	var read int64
+	for {
+		n, err := io.Copy(h, r)
+		read += n
+		if err != nil {
+			printError(readError(name, err))
+			return 1
+		}
+		if fi, err := f.Stat(); err == nil && fi.Size() < read {
+			printError(newHashError("read", name, errors.New("file was truncated")))
+			return 1
+		}
+		if read != offset {
+			offset = read
+			fmt.Printf("follow %s %d %0x %s\n", *fHash, offset, h.Sum(nil), name)
+		}
+
+		select {
+		case <-interrupted:
+			return 0
+		case <-time.After(*fFollowInterval):
+		}
+	}
+}
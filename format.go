@@ -0,0 +1,64 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"text/template"
+)
+
+//Template for -format, nil unless it was given
+var format *template.Template
+
+//What a -format template can use for each hash it prints
+type formatFields struct {
+	Algo string //algorithm, as given to -h
+	Hash string //hash in lowercase hex
+	Path string //file name as given, with -prefix in front, or - for stdin
+	Size int64  //bytes hashed
+}
+
+//Parse -format, always ending lines with a newline, and try it out so a
+//misspelled field fails before any file is hashed
+func parseFormat(text string) error {
+	var err error
+	if format, err = template.New("format").Parse(text + "\n"); err != nil {
+		return err
+	}
+	return format.Execute(ioutil.Discard, formatFields{})
+}
+
+//Print the hash of file computed with algo through the -format template
+func writeFormatted(w io.Writer, file fileHash, algo string, sum []byte) error {
+	var size = file.size
+	if size < 0 {
+		size = file.bytes
+	}
+	return format.Execute(w, formatFields{algo, fmt.Sprintf("%0x", sum), file.listedName(), size})
+}
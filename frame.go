@@ -0,0 +1,93 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+//Bytes hashed ahead of the contents of file, for the options that frame
+//the data before hashing it. In order: the -domain string, then a NUL byte;
+//the file name as given, cleaned and with / separators, then a NUL byte
+//(-bind-path); the size as 8 bytes big-endian (-length-prefix); the
+//metadata of hashes labeled for -with-metadata. Hashes labeled for -git-blob
+//have only the git object header instead.
+func framing(file fileHash) ([]byte, error) {
+	var prefix []byte
+	if file.expectedHashType != nil {
+		if _, ok := gitBlob(*file.expectedHashType); ok {
+			if file.size < 0 {
+				return nil, newHashError("hash", file.displayName(), errors.New("-git-blob needs the size up front, which stdin and pipes can't tell"))
+			}
+			return []byte(fmt.Sprintf("blob %d\x00", file.size)), nil
+		}
+	}
+	if *fDomain != "" {
+		prefix = append(prefix, *fDomain...)
+		prefix = append(prefix, 0)
+	}
+	if *fBindPath {
+		if file.fileName == nil {
+			return nil, newHashError("hash", file.displayName(), errors.New("-bind-path needs a file name, which stdin doesn't have"))
+		}
+		prefix = append(prefix, filepath.ToSlash(filepath.Clean(*file.fileName))...)
+		prefix = append(prefix, 0)
+	}
+	if *fLengthPrefix {
+		if file.size < 0 {
+			return nil, newHashError("hash", file.displayName(), errors.New("-length-prefix needs the size up front, which stdin and pipes can't tell"))
+		}
+		var length [8]byte
+		binary.BigEndian.PutUint64(length[:], uint64(file.size))
+		prefix = append(prefix, length[:]...)
+	}
+	if file.expectedHashType != nil {
+		if _, ok := withMetadata(*file.expectedHashType); ok {
+			meta, err := metadataFraming(file)
+			if err != nil {
+				return nil, err
+			}
+			prefix = append(prefix, meta...)
+		}
+	}
+	return prefix, nil
+}
+
+//Reads prefix and then the file
+type prefixedReader struct {
+	io.Reader
+	io.Closer
+}
+
+func withPrefix(prefix []byte, r io.ReadCloser) io.ReadCloser {
+	return prefixedReader{io.MultiReader(bytes.NewReader(prefix), r), r}
+}
@@ -26,25 +26,126 @@ OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 package main
 
 import (
-	"bufio"
-	"crypto/md5"
-	"crypto/sha1"
-	"crypto/sha256"
-	"crypto/sha512"
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
 	"flag"
 	"fmt"
 	"hash"
-	"hash/crc32"
 	"io"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/dietsche/gohash/hashes"
 )
 
-var fHash = flag.String("h", "sha256", "valid hashes: crc32, md5, sha1, sha224, sha256, sha384, sha512")
+var fHash = flag.String("h", "sha256", "valid hashes: blake3, btv2, cksum, crc32, md5, sha1, sha224, sha256, sha384, sha512. Separate several with commas to compute them all in one pass. Write a FILE as ALGO:FILE to hash just that FILE with ALGO. Without -h, $GOHASH_ALGO is used when set, then sha256.")
 var fConcurrent = flag.Int("j", runtime.NumCPU()*4, "Maximum number of files processed concurrently.")
-var fCheck = flag.Bool("c", false, "Read hash from FILE and verify.")
+var fBindPath = flag.Bool("bind-path", false, "Hash each file's name as given, then a NUL byte, before its contents, so moving a file changes its hash. Use it with -c too.")
+var fCheck = flag.Bool("c", false, "Read hash from FILE and verify. With no FILE, or when FILE is -, read standard input.")
+var fCacheIgnoreMtime = flag.Bool("cache-ignore-mtime", false, "Reuse -cache hashes of files with the same name and size even when their modification time changed, as after touch or a restore. An edit that keeps the size goes unnoticed.")
+var fDupeIndex = flag.String("dupe-index", "", "Remember the first file seen with each hash in this file, across runs, and report each file hashed that duplicates one seen before.")
+var fCache = flag.String("cache", "", "Keep hashes in this file by name, size and modification time, and reuse them instead of reading files that haven't changed. Use it with -c too.")
+var fCDC = flag.Bool("cdc", false, "Split files into content-defined chunks and hash each chunk.")
+var fCDCMin = flag.Int("cdc-min", 2048, "Smallest -cdc chunk in bytes.")
+var fCDCAvg = flag.Int("cdc-avg", 8192, "Average -cdc chunk size in bytes, a power of two.")
+var fCDCMax = flag.Int("cdc-max", 65536, "Largest -cdc chunk in bytes.")
+var fCDCUnordered = flag.Bool("cdc-unordered", false, "With -c -cdc, only check that a file has the listed chunks, in any order and at any offset.")
+var fCRCFormat = flag.String("crc-format", "hex", "How to write crc32 values: hex, le for little-endian hex, dec for unsigned decimal, or signed for signed decimal. -c reads them the same way.")
+var fCheckpoint = flag.Int("checkpoint", 0, "Print the running hash state every N megabytes of each file.")
+var fConfig = flag.String("config", "", "Read default flag settings from this file of name=value lines, e.g. h=sha512. Flags given on the command line win, then the file, then $GOHASH_ALGO.")
+var fCompareTo = flag.String("compare-to", "", "Report which FILEs are the same as in MANIFEST, changed, new or gone, instead of printing hashes. With no FILEs, compare the files MANIFEST lists.")
+var fConcat = flag.Bool("concat", false, "Compute one hash over all FILEs in order, the same as cat FILE... | gohash.")
+var fCompat = flag.Bool("compat", false, "Read and write the same format as md5sum, sha256sum, etc. with the same messages and exit codes.")
+var fDiffBytes = flag.String("diff-bytes", "", "In check mode, compare files under 16MB that fail with the copy of the same name in DIR, and report where they differ.")
+var fDomain = flag.String("domain", "", "Hash STR and a NUL byte before each file, so the same content hashes differently in each context. STR is a public label, not a secret. Use it with -c too.")
+var fEOLHint = flag.Bool("eol-hint", false, "In check mode, say when a text file under 16MB that fails would have matched with Unix or Windows line endings.")
+var fExpect = flag.String("expect", "", "Exit with status 1 unless the hash of the one FILE, or of standard input, is HEX. For verifying downloads in a pipe.")
+var fExternal = flag.String("external", "", "Compute hashes by piping each file into CMD, which prints the hash in hex. Label the hashes with -h NAME.")
+var fFailOnMissing = flag.Bool("fail-on-missing", false, "In check mode, count files listed in FILE that cannot be opened as failures.")
+var fStatus = flag.Bool("status", false, "In check mode, print nothing but errors; the exit status tells whether every file verified.")
+var fSilent = flag.Bool("silent", false, "With -status, don't print errors either.")
+var fFirstMismatch = flag.Bool("first-mismatch-exit", false, "In check mode, stop with status 1 at the first file that fails verification instead of checking the rest.")
+var fPairs = flag.Bool("pairs", false, "In check mode, take each FILE as algorithm=hash=file to check, instead of reading a check file.")
+var fFinalNewline = flag.Bool("final-newline", true, "End written manifests with a newline. -final-newline=false leaves it off the last line, for tools that want it so.")
+var fStrictNewline = flag.Bool("strict-newline", false, "In check mode, warn when FILE doesn't end with a newline, which may mean it was cut short.")
+var fNoExtra = flag.String("no-extra", "", "In check mode, also fail for every file under this directory that FILE doesn't list.")
+var fFix = flag.Bool("fix", false, "In check mode, rewrite FILE with the new hashes of files that changed.")
+var fFixPrune = flag.Bool("fix-prune", false, "With -fix, also remove entries for files that cannot be opened.")
+var fEmitScript = flag.Bool("emit-script", false, "Write a shell script that checks the files with sha256sum and the like, instead of a manifest, for those without gohash.")
+var fFingerprint = flag.Bool("fingerprint", false, "Print a short pronounceable fingerprint of each hash, for comparing by eye, instead of the hash. See README.")
+var fCountOnly = flag.Bool("count-only", false, "Print how many files and bytes the FILEs given come to, without hashing them.")
+var fRepeat = flag.Int("repeat", 0, "Hash each FILE this many times, reopening it each time, and print the min, max and mean time taken instead of hashes.")
+var fDetect = flag.String("detect", "", "Find which algorithm gave this hash by trying every one that could have against each FILE.")
+var fDetectAll = flag.Bool("detect-all", false, "With -detect, print every algorithm that matches rather than stopping at the first.")
+var fFollow = flag.Bool("follow", false, "Keep reading the one FILE as it grows and print its hash so far after each read, until interrupted.")
+var fFollowInterval = flag.Duration("follow-interval", time.Second, "How long -follow waits at the end of FILE before reading on.")
+var fFormat = flag.String("format", "", "Print each hash with this text/template, using {{.Algo}}, {{.Hash}}, {{.Path}} and {{.Size}}.")
+var fGoSum = flag.Bool("go-sum", false, "Print the base64 h1: SHA-256 hash go.sum records for each FILE, hashing directories the way the go command hashes modules.")
+var fGoSumPrefix = flag.String("go-sum-prefix", "", "With -go-sum, the module path@version the files in a directory are named under.")
+var fIgnoreCase = flag.Bool("ignore-case", false, "In check mode, match file names in FILE to files on disk ignoring case.")
+var fJSONManifest = flag.Bool("json-manifest", false, "In check mode, FILE is a JSON array of {\"path\", \"algo\", \"hash\"} objects. A FILE starting with [ is taken to be one without it.")
+var fHeader = flag.Bool("header", false, "Begin the hashes with a line naming the algorithm and format, so -c can read them without being told.")
+var fManifestDigest = flag.Bool("manifest-digest", false, "End the hashes with a line hashing all of them in order, so reordering or truncation can be detected. With -c, check that line before anything else.")
+var fMaxOpen = flag.Int("max-open", 0, "Maximum number of files open at once. Files are opened ahead of the -j workers, so without it up to about 5 times -j can be open. 0 means no limit.")
+var fNoFilename = flag.Bool("no-filename", false, "Print only the hash, one per line in argument order, as for standard input.")
+var fNewerThan = flag.String("newer-than", "", "Only hash FILEs modified after TIME, given in RFC 3339 or as a duration before now such as 24h or 7d. The others count as skipped.")
+var fOlderThan = flag.String("older-than", "", "Only hash FILEs modified before TIME, given like -newer-than.")
+var fMerge = flag.Bool("merge", false, "Combine the manifest FILEs into one, each file once and sorted by name, written to stdout or -o. Files given different hashes are conflicts and fail the merge.")
+var fMergePrefer = flag.String("merge-prefer", "", "With -merge, settle conflicts with the hash from the first or last manifest that has the file.")
+var fOnlyChanged = flag.String("only-changed", "", "Refresh the hashes in MANIFEST, reusing those of files not modified since MANIFEST was. Hashes FILEs, or the files MANIFEST lists.")
+var fADS = flag.String("ads", "", "On Windows, also store the hashes of each FILE in its NTFS alternate data stream of this name, such as checksum.sha256.")
+var fRawDir = flag.String("raw-dir", "", "Also write the binary hash of each FILE to DIR/<base name>.<algo>, adding .2, .3 and so on to base names seen before.")
+var fRawDirMirror = flag.Bool("raw-dir-mirror", false, "With -raw-dir, write to each FILE's own path under DIR instead.")
+var fRawOut = flag.String("raw-out", "", "Also write the binary hash of the one FILE, or of standard input, to this file.")
+var fThreadsPerFile = flag.Int("threads-per-file", 1, "Let the hash of one file use up to N threads, for very large files. Only blake3 can; other hashes ignore it.")
+var fVerifyName = flag.Bool("verify-name", false, "Verify that each FILE holds what its name says, the name starting with its hash up to the first dot as in content addressed stores (<sha256>.blob). Without -h, the algorithm is told by the length of the hash.")
+var fWithMetadata = flag.Bool("with-metadata", false, "Hash each file's size, permissions and modification time along with its contents, so a change to any of them shows. Labeled like meta-sha256.")
+var fGitBlob = flag.Bool("git-blob", false, "Hash files the way git stores them, giving the object IDs of git hash-object. Labeled like git-sha1; -h must be sha1 or sha256.")
+var fSegments = flag.Int("parallel-segments", 0, "Hash each file in N segments at once and combine them. The hash differs from a plain one and is labeled like sha256/N; -c verifies it.")
+var fOutput = flag.String("o", "", "Write the hashes to FILE, in argument order, instead of standard output. FILE is left untouched when it already holds exactly those hashes.")
+var fPermissionsFatal = flag.Bool("permissions-fatal", false, "With -c -verify-permissions or -verify-owner, count files whose permissions or owner changed as failed rather than warning.")
+var fPrefix = flag.String("prefix", "", "Put STR in front of every file name written, to namespace manifests that get merged. With -c it is removed from the names in FILE.")
+var fRequireAlgoMatch = flag.Bool("require-algo-match", false, "In check mode, fail every line of FILE whose algorithm isn't the one given with -h.")
+var fSkipDone = flag.String("skip-done", "", "Don't hash the FILEs listed in this -joblog, to resume an interrupted run.")
+var fSparse = flag.Bool("sparse", false, "Don't read the holes of sparse files from disk. Hashes are the same either way.")
+var fJoblog = flag.String("joblog", "", "Append the name of each FILE to this file once its hash has been printed, for -skip-done.")
+var fLengthPrefix = flag.Bool("length-prefix", false, "Hash the file size as an 8 byte big-endian number before the contents. Not for stdin or pipes.")
+var fSort = flag.Bool("sort", false, "Print hashes sorted by file name, so the same files always give the same output. Nothing is printed until every file is hashed, and all the results are kept in memory until then.")
+var fSplitByAlgo = flag.String("split-by-algo", "", "Write the hashes of each algorithm to DIR/sums.ALGO instead of standard output, like -o.")
+var fWarnSymlink = flag.Bool("warn-symlink", false, "Warn on stderr about each FILE that is a symbolic link, saying what it points to. It is still hashed.")
+var fTCPTimeout = flag.Duration("tcp-timeout", 30*time.Second, "Give up on a tcp://host:port input that doesn't connect, or sends nothing, for this long. 0 waits forever.")
+var fStdinName = flag.String("stdin-name", "", "Name standard input NAME in the output, as if it were a file, instead of printing the bare hash.")
+var fStrictPermissions = flag.Bool("strict-permissions", false, "In check mode, refuse a FILE that anyone may write to, or that is owned by someone other than you or root, since its hashes could have been changed. Unix only.")
+var fStrictHashes = flag.Bool("strict-hashes", false, "In check mode, fail entries whose expected hash is suspicious, such as all zeros or the wrong length, without hashing the file. Otherwise they are only warned about.")
+var fIgnoreMetadataFields = flag.String("ignore-metadata-fields", "", "Zero the timestamps inside files of this format before hashing them, so copies made at different times hash the same. Only zip for now; -c must be given it too.")
+var fCanonicalize = flag.String("canonicalize", "", "Hash json or xml documents in a canonical form, so ones differing only in layout, key order or whitespace hash the same. -c must be given it too.")
+var fStripBOM = flag.Bool("strip-bom", false, "Don't hash a UTF-8 or UTF-16 byte order mark at the start of a file. Use it with -c too.")
+var fStructure = flag.Bool("structure", false, "Hash the names, sizes and modes of everything under each FILE instead of the contents.")
+var fUring = flag.Bool("uring", false, "Read files through io_uring on Linux, if gohash was built with -tags uring.")
+var fVerifyOwner = flag.Bool("verify-owner", false, "Record each file's owner as uid(user):gid(group) between the hash and the name, after any permissions, and with -c, warn when it changed. Unix only; elsewhere - is recorded.")
+var fVerifyPermissions = flag.Bool("verify-permissions", false, "Record each file's permission bits in octal between the hash and the name, and with -c, warn when they changed.")
+var fSyslog = flag.Bool("syslog", false, "In check mode, also send each result to the system log: OK as info, mismatches as warnings, unreadable files as errors.")
+var fChunkSize = flag.Int("chunk-size", 0, "Read files this many bytes at a time, so -progress-json moves in even steps on slow media. Smaller reads cost some speed.")
+var fProgressJSON = flag.Bool("progress-json", false, "Write progress as lines of JSON to stderr, or to the -progress-fd, a few times a second and once when done.")
+var fOutFD = flag.Int("out-fd", 1, "File descriptor to write results to instead of standard output, such as 3 for a pipeline reading them apart from everything else.")
+var fProgressFD = flag.Int("progress-fd", 2, "File descriptor to write -progress-json to.")
+var fSummaryJSON = flag.Bool("summary-json", false, "When done, write a JSON summary of the run to stderr or the -summary-file.")
+var fSummaryFile = flag.String("summary-file", "", "Write the -summary-json summary to this file.")
+var fRatePerFile = flag.String("limit-rate-per-file", "", "Limit how fast each file is read, e.g. 10MB/s, however many are read at once. With -throttle too, whichever is slower wins.")
+var fThrottle = flag.String("throttle", "", "Limit the combined read rate of all files, e.g. 50MB/s. Trades speed for lower system impact.")
+
+//shared by all digesters when -throttle is set
+var throttle *limiter
+
+//bytes per second each file may be read at, with -limit-rate-per-file
+var ratePerFile int64
 
 type fileHash struct {
 	fileName         *string
@@ -52,6 +153,47 @@ type fileHash struct {
 	hash             []byte
 	expectedHashType *string
 	expecteHash      *string
+	size             int64
+	bytes            int64       //read while hashing
+	algos            []string    //every algorithm computed, with -h a,b,...
+	sums             [][]byte    //the hash for each of algos; hash is the first
+	bom              string      //encoding of the byte order mark -strip-bom removed
+	mode             os.FileMode //permission bits, with -verify-permissions
+	cacheInfo        os.FileInfo //the file when it was looked up in the -cache
+	expectedMode     os.FileMode
+	expectedOwner    string
+	chunks           []chunk //computed with -cdc
+	expectedChunks   []chunk
+	line             int //position in the argument list or check file, -1 when not about a file
+	err              error
+}
+
+//Something that went wrong with a single input. op is one of open, read,
+//hash, decode or check.
+type hashError struct {
+	op   string
+	path string
+	err  error
+}
+
+func (e *hashError) Error() string {
+	return e.op + " " + e.path + ": " + e.err.Error()
+}
+
+func newHashError(op, path string, err error) *hashError {
+	if pe, ok := err.(*os.PathError); ok {
+		err = pe.Err
+	}
+	return &hashError{op, path, err}
+}
+
+//Describe a failure to read from the named input, unless the reader
+//already described it
+func readError(name string, err error) error {
+	if _, ok := err.(*hashError); ok {
+		return err
+	}
+	return newHashError("read", name, err)
 }
 
 //Setup flags and sanitize user input
@@ -63,85 +205,705 @@ func handleFlags() {
 	}
 	flag.Parse()
 
+	if *fConfig != "" {
+		if err := readConfig(*fConfig); err != nil {
+			printError(err)
+			os.Exit(2)
+		}
+	}
+
+	if flagGiven("external") && strings.TrimSpace(*fExternal) == "" {
+		printError(errors.New("-external needs a command to run."))
+		os.Exit(2)
+	}
+
+	if *fCheck && !*fPairs && flag.NArg() > 1 {
+		fmt.Fprintf(os.Stderr, "Usage of %s -c: [OPTION]... [FILE]\n", os.Args[0])
+		printError(errors.New("Please specify one file that contains previous hash output from this program, or none to read it from standard input."))
+		os.Exit(2)
+	}
+
 	if *fConcurrent <= 0 {
 		*fConcurrent = 1
 	}
 
+	if algo := os.Getenv("GOHASH_ALGO"); algo != "" && !flagGiven("h") {
+		*fHash = algo
+	}
+	if *fStrictPermissions && *fCheck && !*fPairs {
+		var fi, err = os.Stdin.Stat()
+		if checkFileName() != "-" {
+			fi, err = os.Stat(checkFileName())
+		}
+		if err == nil {
+			if reason := untrusted(fi); reason != "" {
+				printError(fmt.Errorf("%s: not checking against it, %s.", checkFileName(), reason))
+				os.Exit(1)
+			}
+		}
+	}
+
+	if *fCheck && !*fPairs {
+		if err := readManifestHeader(); err != nil {
+			printError(err)
+			os.Exit(2)
+		}
+	}
+	inferAlgo = *fCheck && *fCompat && !flagGiven("h") && os.Getenv("GOHASH_ALGO") == "" && headerLines == 0
+	if *fCheck && !*fPairs && !*fJSONManifest && headerLines == 0 {
+		*fJSONManifest = looksLikeJSON()
+	}
 	*fHash = strings.ToLower(*fHash)
 
+	if err := checkModes(); err != nil {
+		printError(err)
+		os.Exit(2)
+	}
+
+	if *fPairs {
+		var err error
+		if pairs, err = parsePairs(flag.Args()); err != nil {
+			fmt.Fprintf(os.Stderr, "Usage of %s -c -pairs: [OPTION]... ALGORITHM=HASH=FILE...\n", os.Args[0])
+			printError(fmt.Errorf("%v.", err))
+			os.Exit(2)
+		}
+	}
+
+	if *fCheck && *fCompat && *fHash == "cksum" {
+		printError(errors.New("cksum has no check mode to be compatible with; use -c without -compat."))
+		os.Exit(2)
+	}
+
+	if strings.Contains(*fHash, ",") && (*fCheck || *fCompat) {
+		printError(errors.New("Only one hash can be given with -c or -compat."))
+		os.Exit(2)
+	}
+
+	if *fCDC {
+		if err := validCDCSizes(); err != nil {
+			printError(err)
+			os.Exit(2)
+		}
+	}
+
+	if *fRawOut != "" && flag.NArg() > 1 {
+		printError(errors.New("-raw-out needs one FILE or stdin."))
+		os.Exit(2)
+	}
+
+	if *fADS != "" && !haveStreams {
+		printError(errors.New("-ads needs NTFS alternate data streams, which are only on Windows."))
+		os.Exit(2)
+	}
+
+	if !crcFormats[*fCRCFormat] {
+		printError(fmt.Errorf("-crc-format is hex, le, dec or signed, not %s.", *fCRCFormat))
+		os.Exit(2)
+	}
+
+	if *fCanonicalize != "" && *fCanonicalize != "json" && *fCanonicalize != "xml" {
+		printError(fmt.Errorf("-canonicalize is json or xml, not %s.", *fCanonicalize))
+		os.Exit(2)
+	}
+
+	if *fIgnoreMetadataFields != "" && *fIgnoreMetadataFields != "zip" {
+		printError(fmt.Errorf("-ignore-metadata-fields only knows zip, not %s.", *fIgnoreMetadataFields))
+		os.Exit(2)
+	}
+
+	if *fExpect != "" && flag.NArg() > 1 {
+		printError(errors.New("-expect needs one FILE or stdin."))
+		os.Exit(2)
+	}
+
+	if *fCompareTo != "" {
+		if err := readReferences(*fCompareTo); err != nil {
+			printError(err)
+			os.Exit(2)
+		}
+	}
+
+	if *fJoblog != "" {
+		if err := openJoblog(*fJoblog); err != nil {
+			printError(err)
+			os.Exit(2)
+		}
+	}
+
+	if *fDupeIndex != "" {
+		if err := loadDupeIndex(*fDupeIndex); err != nil {
+			printError(err)
+			os.Exit(2)
+		}
+	}
+
+	if *fCache != "" {
+		if err := loadCache(*fCache); err != nil {
+			printError(err)
+			os.Exit(2)
+		}
+	}
+
+	if *fSyslog {
+		if err := openSyslog(); err != nil {
+			printError(fmt.Errorf("-syslog: %s, results are not logged", err.Error()))
+		}
+	}
+
+	if *fMergePrefer != "" && *fMergePrefer != "first" && *fMergePrefer != "last" {
+		printError(errors.New("-merge-prefer is first or last."))
+		os.Exit(2)
+	}
+
+	if *fStatus && !*fCheck && !*fVerifyName {
+		printError(errors.New("-status is for check mode and -verify-name."))
+		os.Exit(2)
+	}
+
+	if *fFollow && flag.NArg() != 1 {
+		printError(errors.New("-follow reads one FILE."))
+		os.Exit(2)
+	}
+
+	if *fEmitScript {
+		for _, algo := range strings.Split(strings.ToLower(*fHash), ",") {
+			if !scriptAlgos[algo] {
+				printError(fmt.Errorf("-emit-script needs a %ssum tool, which there isn't.", algo))
+				os.Exit(2)
+			}
+		}
+	}
+
+	if *fRepeat < 0 {
+		printError(errors.New("-repeat must not be negative."))
+		os.Exit(2)
+	}
+
+	if *fDetect != "" && flagGiven("h") {
+		printError(errors.New("-detect tries every algorithm itself, so not with -h."))
+		os.Exit(2)
+	}
+
+	if *fFormat != "" {
+		if err := parseFormat(*fFormat); err != nil {
+			printError(fmt.Errorf("-format: %s", err.Error()))
+			os.Exit(2)
+		}
+	}
+
+	if *fGoSum {
+		if flagGiven("h") && *fHash != "sha256" {
+			printError(errors.New("-go-sum hashes FILEs with sha256."))
+			os.Exit(2)
+		}
+		*fHash = "sha256"
+	}
+
+	if *fThreadsPerFile > 1 {
+		if !*fCheck {
+			for _, algo := range strings.Split(*fHash, ",") {
+				if algo != "blake3" {
+					printError(fmt.Errorf("-threads-per-file only applies to blake3, %s is hashed on one thread.", algo))
+				}
+			}
+		}
+		hashes.ThreadsPerFile = *fThreadsPerFile
+	}
+
+	if *fGitBlob {
+		if !flagGiven("h") {
+			//what git uses unless the repository says otherwise
+			*fHash = "sha1"
+		}
+		if !*fCheck {
+			var labels []string
+			for _, algo := range strings.Split(*fHash, ",") {
+				if algo != "sha1" && algo != "sha256" {
+					printError(fmt.Errorf("git names objects by sha1 or sha256, not %s.", algo))
+					os.Exit(2)
+				}
+				labels = append(labels, "git-"+algo)
+			}
+			*fHash = strings.Join(labels, ",")
+		}
+	}
+
+	if *fWithMetadata {
+		if !*fCheck {
+			var labels []string
+			for _, algo := range strings.Split(*fHash, ",") {
+				labels = append(labels, "meta-"+algo)
+			}
+			*fHash = strings.Join(labels, ",")
+		}
+	}
+
+	if *fSegments > 0 {
+		if !*fCheck {
+			*fHash = fmt.Sprintf("%s/%d", *fHash, *fSegments)
+		}
+	}
+
+	if *fExternal != "" && !flagGiven("h") {
+		*fHash = "external"
+	}
+
+	if *fThrottle != "" {
+		rate, err := parseRate(*fThrottle)
+		if err != nil {
+			printError(fmt.Errorf("-throttle: %s", err.Error()))
+			os.Exit(2)
+		}
+		throttle = newLimiter(rate)
+	}
+	for _, when := range []struct {
+		name string
+		flag *string
+		t    *time.Time
+	}{{"newer-than", fNewerThan, &newerThan}, {"older-than", fOlderThan, &olderThan}} {
+		if *when.flag == "" {
+			continue
+		}
+		t, err := parseWhen(*when.flag)
+		if err != nil {
+			printError(fmt.Errorf("-%s: %s", when.name, err.Error()))
+			os.Exit(2)
+		}
+		*when.t = t
+	}
+	if (*fNewerThan != "" || *fOlderThan != "") && flag.NArg() == 0 && *fCompareTo == "" {
+		printError(errors.New("-newer-than and -older-than choose among the FILEs to hash, so need some."))
+		os.Exit(2)
+	}
+
+	if *fRatePerFile != "" {
+		rate, err := parseRate(*fRatePerFile)
+		if err != nil {
+			printError(fmt.Errorf("-limit-rate-per-file: %s", err.Error()))
+			os.Exit(2)
+		}
+		ratePerFile = rate
+	}
+
+	if *fChunkSize < 0 {
+		printError(errors.New("-chunk-size must not be negative."))
+		os.Exit(2)
+	}
+	if *fOutFD != 1 {
+		f, err := openFD(*fOutFD, "out")
+		if err != nil {
+			printError(fmt.Errorf("-out-fd: %s", err.Error()))
+			os.Exit(2)
+		}
+		//everything meant for standard output goes to f instead
+		os.Stdout = f
+	}
+	if *fProgressJSON {
+		f, err := openFD(*fProgressFD, "progress")
+		if err != nil {
+			printError(fmt.Errorf("-progress-fd: %s", err.Error()))
+			os.Exit(2)
+		}
+		prog = startProgress(f)
+	} else if flagGiven("progress-fd") {
+		printError(errors.New("-progress-fd needs -progress-json."))
+		os.Exit(2)
+	}
+
+	if *fMaxOpen > 0 {
+		openSlots = make(chan struct{}, *fMaxOpen)
+	}
+
+	if *fUring {
+		if err := startUring(); err != nil {
+			printError(fmt.Errorf("-uring: %s, using ordinary reads", err.Error()))
+		}
+	}
+
 	runtime.GOMAXPROCS(runtime.NumCPU())
 }
 
+//Whether the named flag was set on the command line
+func flagGiven(name string) bool {
+	given := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			given = true
+		}
+	})
+	return given
+}
+
 //Do your thing
 func main() {
 	handleFlags()
+	if *fFollow {
+		os.Exit(follow(flag.Arg(0)))
+	}
+	if *fMerge {
+		os.Exit(merge(flag.Args()))
+	}
+	if *fRepeat > 0 {
+		os.Exit(repeat(*fRepeat, flag.Args()))
+	}
+	if *fDetect != "" {
+		os.Exit(detect(*fDetect, flag.Args()))
+	}
+
 	in := make(chan fileHash, *fConcurrent*2)
 	out := make(chan fileHash, *fConcurrent*2)
 
-	if *fCheck {
+	var results <-chan fileHash = out
+	if *fCompat || *fOutput != "" || *fSplitByAlgo != "" || *fNoFilename || *fCompareTo != "" || *fRawDir != "" || *fManifestDigest && !*fCheck {
+		results = inOrder(out)
+	}
+	if *fSort {
+		results = sortedByName(results)
+	}
+
+	status := 0
+	if *fCountOnly {
+		go openFilesForHashing(in)
+
+		status = countOnly(in)
+	} else if *fCompareTo != "" {
+		go openFilesForHashing(in)
+		go hashFiles(out, in)
+
+		status = reportComparison(results)
+	} else if *fVerifyName {
+		go openFilesForNames(in)
+		go hashFiles(out, in)
+
+		status = reportCheckResults(results)
+	} else if *fPairs {
+		go openFilesForPairs(in)
+		go hashFiles(out, in)
+
+		status = reportCheckResults(results)
+	} else if *fCheck {
 		go openFilesForCheck(in)
 		go hashFiles(out, in)
 
-		for curResult := range out {
-			var computed = fmt.Sprintf("%0x", curResult.hash)
-			fmt.Printf("%s %t\n", *curResult.fileName, computed == *curResult.expecteHash)
-		}
+		status = reportCheckResults(results)
 	} else {
 		go openFilesForHashing(in)
 		go hashFiles(out, in)
 
-		for curResult := range out {
-			if curResult.fileName == nil {
-				fmt.Printf("%0x\n", curResult.hash)
+		var w io.Writer = manifestWriter(os.Stdout)
+		var manifest bytes.Buffer
+		var raw []byte
+		var digested [][]byte
+		if *fOutput != "" {
+			w = &manifest
+		}
+		//with -split-by-algo, the manifest of each algorithm
+		var split = make(map[string]*bytes.Buffer)
+		writerFor := func(algo string) io.Writer {
+			if *fSplitByAlgo == "" {
+				return w
+			}
+			if split[algo] == nil {
+				split[algo] = new(bytes.Buffer)
+			}
+			return split[algo]
+		}
+
+		if *fHeader {
+			fmt.Fprintln(w, manifestHeader(*fHash))
+		}
+		if *fEmitScript {
+			io.WriteString(w, scriptHeader)
+		}
+		for curResult := range results {
+			if curResult.err != nil {
+				printError(curResult.err)
+				summary.Failed++
+				status = 1
+				continue
+			}
+
+			summary.add(curResult)
+			raw = curResult.hash
+			if *fDupeIndex != "" && curResult.fileName != nil && len(curResult.algos) > 0 {
+				if first, ok := dupeOf(curResult.algos[0], hashText(curResult.algos[0], curResult.sums[0]), *curResult.fileName); ok {
+					fmt.Fprintf(os.Stderr, "%s: duplicate of %s\n", curResult.listedName(), first)
+				}
+			}
+			if *fCDC {
+				summary.addAlgorithm(*curResult.expectedHashType)
+			}
+			for _, c := range curResult.chunks {
+				fmt.Fprintf(writerFor(*curResult.expectedHashType), "%s %0x %d+%d %s\n", *curResult.expectedHashType, c.hash, c.offset, c.length, curResult.listedName())
+			}
+			if curResult.bom != "" {
+				fmt.Fprintf(os.Stderr, "%s: stripped %s byte order mark\n", curResult.displayName(), curResult.bom)
+			}
+			digested = append(digested, curResult.sums...)
+			for i, algo := range curResult.algos {
+				w := writerFor(algo)
+				if format != nil {
+					if err := writeFormatted(w, curResult, algo, curResult.sums[i]); err != nil {
+						printError(err)
+						status = 1
+					}
+				} else if *fEmitScript {
+					fmt.Fprint(w, scriptLine(algo, curResult.sums[i], curResult.listedName()))
+				} else if *fFingerprint {
+					fmt.Fprintf(w, "%s %s %s\n", algo, fingerprint(curResult.sums[i]), curResult.listedName())
+				} else if *fGoSum {
+					fmt.Fprintf(w, "h1:%s %s\n", base64.StdEncoding.EncodeToString(curResult.sums[i]), curResult.listedName())
+				} else if *fNoFilename {
+					fmt.Fprintf(w, "%s\n", hashText(algo, curResult.sums[i]))
+				} else if *fCompat && algo == "cksum" {
+					//cksum's own "<crc> <bytes> <file>", with no name for stdin
+					fmt.Fprintf(w, "%d %d", binary.BigEndian.Uint32(curResult.sums[i]), curResult.bytes)
+					if curResult.fileName != nil {
+						fmt.Fprintf(w, " %s", curResult.listedName())
+					}
+					fmt.Fprintln(w)
+				} else if *fCompat {
+					prefix, name := compatEscape(curResult.listedName(), false)
+					fmt.Fprintf(w, "%s%s  %s\n", prefix, hashText(algo, curResult.sums[i]), name)
+				} else if curResult.fileName == nil && len(curResult.algos) == 1 {
+					fmt.Fprintf(w, "%s\n", hashText(algo, curResult.sums[i]))
+				} else if *fVerifyPermissions || *fVerifyOwner {
+					fmt.Fprintf(w, "%s %s %s%s\n", algo, hashText(algo, curResult.sums[i]), recordedColumns(curResult), curResult.listedName())
+				} else {
+					fmt.Fprintf(w, "%s %s %s\n", algo, hashText(algo, curResult.sums[i]), curResult.listedName())
+				}
+			}
+			if *fADS != "" && curResult.fileName != nil {
+				var stored bytes.Buffer
+				for i, algo := range curResult.algos {
+					fmt.Fprintf(&stored, "%s %s\n", algo, hashText(algo, curResult.sums[i]))
+				}
+				if err := writeStream(*curResult.fileName, *fADS, stored.Bytes()); err != nil {
+					//not NTFS, most likely; the hashes were still printed
+					fmt.Fprintf(os.Stderr, "%s: could not store the hashes in stream %s: %s\n", *curResult.fileName, *fADS, err.Error())
+				}
+			}
+			if *fRawDir != "" {
+				for i, algo := range curResult.algos {
+					if err := writeSidecar(curResult.displayName(), algo, curResult.sums[i]); err != nil {
+						printError(err)
+						status = 1
+					}
+				}
+			}
+			if curResult.fileName != nil {
+				logDone(*curResult.fileName)
+			}
+		}
+
+		if *fEmitScript {
+			io.WriteString(w, scriptFooter)
+		}
+		if *fManifestDigest {
+			if digestLine, err := manifestDigestLine(strings.Split(*fHash, ",")[0], digested); err != nil {
+				printError(err)
+				status = 1
 			} else {
-				fmt.Printf("%s %0x %s\n", *fHash, curResult.hash, *curResult.fileName)
+				fmt.Fprintln(w, digestLine)
+			}
+		}
+		if *fRawOut != "" && len(raw) > 0 {
+			if err := writeFileAtomic(*fRawOut, raw, 0644); err != nil {
+				printError(err)
+				status = 1
+			}
+		}
+		if *fExpect != "" {
+			if computed := fmt.Sprintf("%0x", raw); raw == nil {
+				status = 1
+			} else if !strings.EqualFold(computed, *fExpect) {
+				fmt.Fprintf(os.Stderr, "expected %s, got %s\n", strings.ToLower(*fExpect), computed)
+				status = 1
 			}
 		}
+		if *fOutput != "" {
+			if err := writeManifest(*fOutput, manifest.Bytes()); err != nil {
+				printError(err)
+				status = 1
+			}
+		}
+		if err := writeSplit(*fSplitByAlgo, split); err != nil {
+			printError(err)
+			status = 1
+		}
+		if *fDupeIndex != "" {
+			if err := saveDupeIndex(*fDupeIndex); err != nil {
+				printError(err)
+				status = 1
+			}
+		}
+	}
+
+	summary.Skipped += outsideWindow
+	if *fCache != "" {
+		if err := saveCache(*fCache); err != nil {
+			printError(err)
+			status = 1
+		}
 	}
+	prog.finish()
+	writeSummary()
+	os.Exit(status)
 }
 
-func openFilesForCheck(in chan<- fileHash) {
-	defer close(in)
+//Pass results on in the order their files were given rather than the order
+//they finished in. Results that aren't about any one file go straight through.
+func inOrder(out <-chan fileHash) <-chan fileHash {
+	ordered := make(chan fileHash, cap(out))
+	go func() {
+		defer close(ordered)
+		pending := make(map[int]fileHash)
+		next := headerLines
+		for curResult := range out {
+			if curResult.line < 0 {
+				ordered <- curResult
+				continue
+			}
+			pending[curResult.line] = curResult
+			for r, ok := pending[next]; ok; r, ok = pending[next] {
+				ordered <- r
+				delete(pending, next)
+				next++
+			}
+		}
 
-	if flag.NArg() != 1 {
-		fmt.Fprintln(os.Stderr, "Please specify a file that contains previous hash output from this program.")
-		return
-	}
+		//lines that never came, such as those of a chunk manifest after the
+		//first for each file, must not hold back the rest
+		var lines []int
+		for line := range pending {
+			lines = append(lines, line)
+		}
+		sort.Ints(lines)
+		for _, line := range lines {
+			ordered <- pending[line]
+		}
+	}()
+	return ordered
+}
 
-	checkFile, err := os.Open(flag.Arg(0))
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err.Error())
+//Hold back every result until out is drained, then send them sorted by file
+//name for -sort
+func sortedByName(out <-chan fileHash) <-chan fileHash {
+	sorted := make(chan fileHash, cap(out))
+	go func() {
+		defer close(sorted)
+		var all []fileHash
+		for curResult := range out {
+			all = append(all, curResult)
+		}
+		sort.SliceStable(all, func(i, j int) bool {
+			return all[i].displayName() < all[j].displayName()
+		})
+		for _, curResult := range all {
+			sorted <- curResult
+		}
+	}()
+	return sorted
+}
+
+//All errors are reported to the user from here
+func printError(err error) {
+	//-silent on its own is refused, which has to be said
+	if *fSilent && *fStatus {
 		return
 	}
-	defer checkFile.Close()
-
-	s := bufio.NewScanner(checkFile)
-	for s.Scan() {
-		var splits = strings.Split(s.Text(), " ")
-		if stream, err := os.Open(splits[2]); err == nil {
-			in <- fileHash{&splits[2], stream, nil, &splits[0], &splits[1]}
-		} else {
-			fmt.Fprintln(os.Stderr, err.Error())
-		}
+	if *fCompat {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", compatName(), compatError(err))
+		return
 	}
+	fmt.Fprintln(os.Stderr, err.Error())
 }
 
 func openFilesForHashing(in chan<- fileHash) {
 	defer close(in)
-	if flag.NArg() == 0 {
-		in <- fileHash{nil, os.Stdin, nil, fHash, nil}
+
+	var names = flag.Args()
+	var reuse map[string][]byte
+	if *fOnlyChanged != "" {
+		var err error
+		if names, reuse, err = unchangedHashes(*fOnlyChanged, names); err != nil {
+			in <- fileHash{line: -1, err: err}
+			return
+		}
+	}
+
+	if *fSkipDone != "" {
+		var err error
+		if names, err = skipDone(*fSkipDone, names); err != nil {
+			in <- fileHash{line: -1, err: err}
+			return
+		}
+		if len(names) == 0 {
+			return
+		}
+	}
+
+	if *fCompareTo != "" && len(names) == 0 {
+		names = referenceNames
+	}
+
+	if len(names) > 0 {
+		if names = inWindow(names); len(names) == 0 {
+			return
+		}
+	}
+
+	if len(names) == 0 && *fOnlyChanged == "" && *fCompareTo == "" {
+		acquireOpen()
+		var file = fileHash{r: os.Stdin, expectedHashType: fHash, size: -1}
+		if *fStdinName != "" {
+			file.fileName = fStdinName
+		}
+		in <- file
+	} else if *fConcat {
+		acquireOpen()
+		in <- fileHash{r: &concatReader{names: names}, expectedHashType: fHash, size: -1}
 	} else {
-		for i := range flag.Args() {
-			file := flag.Arg(i)
-			if stream, err := os.Open(file); err == nil {
-				in <- fileHash{&file, stream, nil, fHash, nil}
+		for i := range names {
+			algo, file := fHash, names[i]
+			if flag.NArg() > 0 {
+				algo, file = argAlgo(file)
+			}
+			if *fWarnSymlink {
+				warnSymlink(file)
+			}
+			if ref, ok := references[file]; ok {
+				//hash it the way the -compare-to manifest did
+				algo = &ref.algo
+			}
+			var algos = strings.Split(*algo, ",")
+			if hash, ok := reuse[file]; ok && algo == fHash {
+				in <- fileHash{fileName: &file, expectedHashType: fHash, hash: hash, algos: []string{*fHash}, sums: [][]byte{hash}, mode: permissions(file), line: i}
+			} else if sums, info, ok := cachedSums(algos, file); ok {
+				in <- fileHash{fileName: &file, expectedHashType: algo, hash: sums[0], algos: algos, sums: sums, mode: permissions(file), line: i}
+			} else if stream, size, err := openInput(file); err == nil {
+				in <- fileHash{fileName: &file, r: stream, expectedHashType: algo, size: size, mode: permissions(file), cacheInfo: info, line: i}
 			} else {
-				fmt.Fprintln(os.Stderr, err.Error())
+				in <- fileHash{fileName: &file, line: i, err: newHashError("open", file, err)}
 			}
 		}
 	}
 }
 
+//Split an ALGO:FILE argument into the algorithm to hash FILE with and its
+//name. Only algorithms gohash knows count as a prefix, so ./md5:FILE names a
+//file called md5:FILE. -compat output has no algorithm column, so there
+//every argument is a file name.
+func argAlgo(arg string) (*string, string) {
+	if i := strings.Index(arg, ":"); i > 0 && !*fCompat {
+		algo := strings.ToLower(arg[:i])
+		if _, err := hashes.NewHasher(algo); err == nil {
+			return &algo, arg[i+1:]
+		}
+	}
+	return fHash, arg
+}
+
 func hashFiles(out chan<- fileHash, in <-chan fileHash) {
 	defer close(out)
 	var wg sync.WaitGroup
@@ -154,34 +916,188 @@ func hashFiles(out chan<- fileHash, in <-chan fileHash) {
 
 func digester(wg *sync.WaitGroup, out chan<- fileHash, streams <-chan fileHash) {
 	for file := range streams {
-		var hash hash.Hash
-
-		switch *file.expectedHashType {
-		case "crc32":
-			hash = crc32.NewIEEE()
-		case "md5":
-			hash = md5.New()
-		case "sha1":
-			hash = sha1.New()
-		case "sha224":
-			hash = sha256.New224()
-		case "sha256":
-			hash = sha256.New()
-		case "sha384":
-			hash = sha512.New384()
-		case "sha512":
-			hash = sha512.New()
-		default:
-			fmt.Fprintf(os.Stderr, "%s: I don't know how to compute a %s hash!\n", *file.fileName, *file.expectedHashType)
+		//nothing to read when the file failed to open or its hash is already known
+		if file.err != nil || file.r == nil {
+			out <- file
+			continue
+		}
+
+		if algo, n, ok := segmented(*file.expectedHashType); ok {
+			file.hash, file.bytes, file.err = segmentDigest(file, algo, n)
+			file.algos, file.sums = []string{*file.expectedHashType}, [][]byte{file.hash}
+			if file.err == nil {
+				cacheResult(file)
+			}
 			file.r.Close()
+			releaseOpen()
+			out <- file
 			continue
 		}
 
-		io.Copy(hash, file.r)
+		if f, ok := file.r.(*os.File); ok && *fSparse {
+			file.r = sparseFile(f, file.size)
+		} else if ok && *fUring {
+			file.r = uringFile(f)
+		}
+		if throttle != nil {
+			file.r = &throttledReader{file.r, throttle}
+		}
+		if ratePerFile > 0 {
+			file.r = &throttledReader{file.r, newLimiter(ratePerFile)}
+		}
+
+		if prog != nil {
+			file.r = progressReader{file.r, file.displayName()}
+		}
+		counter := &countingReader{ReadCloser: file.r}
+		file.r = counter
+		if *fStripBOM {
+			file.r, file.bom = stripBOM(file.r)
+		}
+		if *fCanonicalize != "" {
+			data, err := canonicalize(*fCanonicalize, file.r)
+			if err != nil {
+				file.r.Close()
+				releaseOpen()
+				file.err = newHashError("read", file.displayName(), err)
+				out <- file
+				continue
+			}
+			file.r = prefixedReader{bytes.NewReader(data), file.r}
+			file.size = int64(len(data))
+		}
+		if *fIgnoreMetadataFields != "" {
+			data, err := stableZip(file.r)
+			if err != nil {
+				file.r.Close()
+				releaseOpen()
+				file.err = newHashError("read", file.displayName(), err)
+				out <- file
+				continue
+			}
+			file.r = prefixedReader{bytes.NewReader(data), file.r}
+		}
+		if prefix, err := framing(file); err != nil {
+			file.r.Close()
+			releaseOpen()
+			file.err = err
+			out <- file
+			continue
+		} else if len(prefix) > 0 {
+			file.r = withPrefix(prefix, file.r)
+		}
+
+		if *fCDC {
+			file.chunks, file.err = chunkDigest(file)
+		} else if *fExternal != "" && *file.expectedHashType == *fHash {
+			file.hash, file.err = externalDigest(file)
+			file.algos, file.sums = []string{*fHash}, [][]byte{file.hash}
+		} else if file.algos, file.sums, file.err = digest(file); file.err == nil {
+			file.hash = file.sums[0]
+			cacheResult(file)
+		}
 		file.r.Close()
-		file.hash = hash.Sum(nil)
+		releaseOpen()
+		file.bytes = counter.n
 
 		out <- file
 	}
 	wg.Done()
 }
+
+//Hash the contents of file with every algorithm in its comma separated
+//expectedHashType. The file is read once, through an io.MultiWriter when
+//there is more than one algorithm.
+func digest(file fileHash) ([]string, [][]byte, error) {
+	var algos = strings.Split(*file.expectedHashType, ",")
+	var hashers = make([]hash.Hash, len(algos))
+	var writers = make([]io.Writer, len(algos))
+	for i, algo := range algos {
+		algo, _ := gitBlob(algo)
+		algo, _ = withMetadata(algo)
+		hash, err := hashes.Borrow(algo)
+		if err != nil {
+			return nil, nil, newHashError("hash", file.displayName(), err)
+		}
+		defer hashes.Return(algo, hash)
+		hashers[i], writers[i] = hash, hash
+	}
+
+	var w = writers[0]
+	if len(writers) > 1 {
+		w = io.MultiWriter(writers...)
+	}
+
+	var err error
+	if *fCheckpoint > 0 {
+		err = checkpointCopy(w, hashers, algos, file)
+	} else if _, err = copyChunked(w, file.r); err != nil {
+		err = readError(file.displayName(), err)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var sums = make([][]byte, len(hashers))
+	for i, hash := range hashers {
+		sums[i] = hash.Sum(nil)
+	}
+	return algos, sums, nil
+}
+
+//Name used to report on a file; stdin has no name of its own
+func (file *fileHash) displayName() string {
+	if file.fileName == nil {
+		return "-"
+	}
+	return *file.fileName
+}
+
+//Name written to a hash line, with -prefix in front
+func (file *fileHash) listedName() string {
+	return *fPrefix + file.displayName()
+}
+
+//Says once that -checkpoint can't be done for an algorithm
+var noCheckpoints sync.Once
+
+//Feed file into w, printing the marshaled state of each of its hashes every
+//*fCheckpoint megabytes
+func checkpointCopy(w io.Writer, hashers []hash.Hash, algos []string, file fileHash) error {
+	name := file.displayName()
+
+	var marshalers = make([]encoding.BinaryMarshaler, len(hashers))
+	for i, hash := range hashers {
+		m, ok := hash.(encoding.BinaryMarshaler)
+		if !ok {
+			//hash it anyway, only without checkpoints
+			noCheckpoints.Do(func() {
+				fmt.Fprintf(os.Stderr, "checkpointing is unavailable for %s, hashing without it\n", algos[i])
+			})
+			if _, err := copyChunked(w, file.r); err != nil {
+				return readError(name, err)
+			}
+			return nil
+		}
+		marshalers[i] = m
+	}
+
+	step := int64(*fCheckpoint) << 20
+	var offset int64
+	for {
+		n, err := io.CopyN(w, file.r, step)
+		offset += n
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return readError(name, err)
+		}
+		for i, m := range marshalers {
+			state, err := m.MarshalBinary()
+			if err != nil {
+				return newHashError("hash", name, err)
+			}
+			fmt.Printf("checkpoint %s %d %0x %s\n", algos[i], offset, state, name)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+//With GOHASH_TEST_MAIN set the test binary runs as gohash, so tests can
+//check what the command prints
+func TestMain(m *testing.M) {
+	if os.Getenv("GOHASH_TEST_MAIN") != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+//Run gohash with args and return what it wrote to stdout
+func runGohash(t *testing.T, args ...string) string {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "GOHASH_TEST_MAIN=1")
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("gohash %s: %v", strings.Join(args, " "), err)
+	}
+	return string(out)
+}
+
+//Write each of contents to its own file in a temporary directory
+func writeFiles(t *testing.T, contents ...string) []string {
+	t.Helper()
+	var dir = t.TempDir()
+	var names []string
+	for i, content := range contents {
+		name := filepath.Join(dir, fmt.Sprintf("file%d", i))
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestConcat(t *testing.T) {
+	var contents = []string{"hello\n", "", "world\n", "no newline"}
+	var names = writeFiles(t, contents...)
+
+	var got = strings.TrimSpace(runGohash(t, append([]string{"-concat"}, names...)...))
+	var want = fmt.Sprintf("%0x", sha256.Sum256([]byte(strings.Join(contents, ""))))
+	if got != want {
+		t.Errorf("-concat printed %q, want %q", got, want)
+	}
+}
+
+//A file that counts how often it is read through and closed
+type countedFile struct {
+	countingReader
+	closes int
+}
+
+func (f *countedFile) Close() error {
+	f.closes++
+	return nil
+}
+
+func TestMultiHashReadsOnce(t *testing.T) {
+	var data = bytes.Repeat([]byte("gohash"), 100000)
+	var file = &countedFile{countingReader: countingReader{ReadCloser: ioutil.NopCloser(bytes.NewReader(data))}}
+	var algo, name = "sha256,md5", "file"
+
+	var in, out = make(chan fileHash, 1), make(chan fileHash, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	in <- fileHash{r: file, fileName: &name, expectedHashType: &algo, size: int64(len(data))}
+	close(in)
+	digester(&wg, out, in)
+
+	var result = <-out
+	if result.err != nil {
+		t.Fatal(result.err)
+	}
+	if file.n != int64(len(data)) || file.closes != 1 {
+		t.Errorf("read %d bytes and closed %d times, want %d bytes and 1 close", file.n, file.closes, len(data))
+	}
+	var sha, md = sha256.Sum256(data), md5.Sum(data)
+	if len(result.sums) != 2 || !bytes.Equal(result.sums[0], sha[:]) || !bytes.Equal(result.sums[1], md[:]) {
+		t.Errorf("got sums %x, want %x and %x", result.sums, sha, md)
+	}
+}
+
+func TestCksumCompat(t *testing.T) {
+	var names = writeFiles(t, "", "123456789")
+
+	var got = runGohash(t, "-h", "cksum", "-compat", names[0], names[1])
+	var want = fmt.Sprintf("4294967295 0 %s\n930766865 9 %s\n", names[0], names[1])
+	if got != want {
+		t.Errorf("-h cksum -compat printed %q, want %q", got, want)
+	}
+}
+
+//Every flag in modeRules and flagNeeds has to exist, and be off when not
+//given, or its combinations go unchecked
+func TestModeRulesNameFlags(t *testing.T) {
+	var names []string
+	for _, rule := range modeRules {
+		names = append(names, rule.flag)
+		names = append(names, rule.not...)
+	}
+	for _, need := range flagNeeds {
+		names = append(names, need.flag, need.needs)
+	}
+	for _, name := range names {
+		f := flag.Lookup(name)
+		if f == nil {
+			t.Errorf("-%s is in the mode rules but isn't a flag", name)
+		} else if flagOn(name) && f.Value.String() == f.DefValue {
+			t.Errorf("-%s counts as given by default", name)
+		}
+	}
+}
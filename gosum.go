@@ -0,0 +1,108 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dietsche/gohash/hashes"
+)
+
+//The input whose SHA-256 is the h1: hash the go command records in go.sum,
+//for -go-sum. That hash is computed over a summary of the files it covers,
+//sorted by name, one line each:
+//
+//	<sha256 in hex>  <name>\n
+//
+//For a directory the files are every file under it, named relative to it
+//with / separators and -go-sum-prefix in front, the same as dirhash.HashDir
+//in golang.org/x/mod. A single file is listed under its base name alone,
+//with no prefix, which is how go.sum hashes a module's go.mod.
+func goSumReader(root string) (io.ReadCloser, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+
+	//listed name to path on disk
+	var files = make(map[string]string)
+	if !info.IsDir() {
+		files[filepath.Base(root)] = root
+	} else if err = filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		files[path.Join(*fGoSumPrefix, filepath.ToSlash(rel))] = file
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	var names = make([]string, 0, len(files))
+	for name := range files {
+		if strings.Contains(name, "\n") {
+			return nil, fmt.Errorf("%q: file names with newlines cannot be hashed for go.sum", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	r, w := io.Pipe()
+	go func() {
+		h, _ := hashes.Borrow("sha256")
+		defer hashes.Return("sha256", h)
+		for _, name := range names {
+			f, err := os.Open(files[name])
+			if err != nil {
+				w.CloseWithError(newHashError("open", files[name], err))
+				return
+			}
+			h.Reset()
+			_, err = io.Copy(h, f)
+			f.Close()
+			if err != nil {
+				w.CloseWithError(readError(files[name], err))
+				return
+			}
+			if _, err = fmt.Fprintf(w, "%x  %s\n", h.Sum(nil), name); err != nil {
+				return
+			}
+		}
+		w.Close()
+	}()
+	return r, nil
+}
@@ -0,0 +1,261 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package hashes
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+	"sync"
+)
+
+//ThreadsPerFile is how many goroutines one blake3 hash may use at once.
+//BLAKE3 is a tree of 1 KiB chunks, so large inputs are hashed a batch of
+//chunks at a time, the batch split between the goroutines. Other algorithms
+//ignore it.
+var ThreadsPerFile = 1
+
+const (
+	blake3ChunkLen = 1024
+	blake3BlockLen = 64
+
+	//chunks each goroutine takes from a batch
+	blake3ChunksPerThread = 16
+
+	blake3ChunkStart = 1 << 0
+	blake3ChunkEnd   = 1 << 1
+	blake3Parent     = 1 << 2
+	blake3Root       = 1 << 3
+)
+
+var blake3IV = [8]uint32{0x6A09E667, 0xBB67AE85, 0x3C6EF372, 0xA54FF53A, 0x510E527F, 0x9B05688C, 0x1F83D9AB, 0x5BE0CD19}
+
+var blake3Permutation = [16]int{2, 6, 3, 10, 7, 0, 4, 13, 1, 11, 12, 5, 9, 14, 15, 8}
+
+func blake3G(s *[16]uint32, a, b, c, d int, mx, my uint32) {
+	s[a] += s[b] + mx
+	s[d] = bits.RotateLeft32(s[d]^s[a], -16)
+	s[c] += s[d]
+	s[b] = bits.RotateLeft32(s[b]^s[c], -12)
+	s[a] += s[b] + my
+	s[d] = bits.RotateLeft32(s[d]^s[a], -8)
+	s[c] += s[d]
+	s[b] = bits.RotateLeft32(s[b]^s[c], -7)
+}
+
+func blake3Compress(cv *[8]uint32, block *[16]uint32, counter uint64, blockLen, flags uint32) [16]uint32 {
+	var s = [16]uint32{
+		cv[0], cv[1], cv[2], cv[3], cv[4], cv[5], cv[6], cv[7],
+		blake3IV[0], blake3IV[1], blake3IV[2], blake3IV[3],
+		uint32(counter), uint32(counter >> 32), blockLen, flags,
+	}
+	var m = *block
+	for round := 0; round < 7; round++ {
+		blake3G(&s, 0, 4, 8, 12, m[0], m[1])
+		blake3G(&s, 1, 5, 9, 13, m[2], m[3])
+		blake3G(&s, 2, 6, 10, 14, m[4], m[5])
+		blake3G(&s, 3, 7, 11, 15, m[6], m[7])
+		blake3G(&s, 0, 5, 10, 15, m[8], m[9])
+		blake3G(&s, 1, 6, 11, 12, m[10], m[11])
+		blake3G(&s, 2, 7, 8, 13, m[12], m[13])
+		blake3G(&s, 3, 4, 9, 14, m[14], m[15])
+
+		var permuted [16]uint32
+		for i, j := range blake3Permutation {
+			permuted[i] = m[j]
+		}
+		m = permuted
+	}
+	for i := 0; i < 8; i++ {
+		s[i] ^= s[i+8]
+		s[i+8] ^= cv[i]
+	}
+	return s
+}
+
+//What the last compression of a chunk or parent node takes, kept so it can
+//be done either as a node within the tree or as the root
+type blake3Output struct {
+	cv       [8]uint32
+	block    [16]uint32
+	counter  uint64
+	blockLen uint32
+	flags    uint32
+}
+
+func (o *blake3Output) chainingValue() (cv [8]uint32) {
+	var s = blake3Compress(&o.cv, &o.block, o.counter, o.blockLen, o.flags)
+	copy(cv[:], s[:8])
+	return
+}
+
+func (o *blake3Output) root() []byte {
+	var s = blake3Compress(&o.cv, &o.block, 0, o.blockLen, o.flags|blake3Root)
+	var sum = make([]byte, 32)
+	for i := 0; i < 8; i++ {
+		binary.LittleEndian.PutUint32(sum[4*i:], s[i])
+	}
+	return sum
+}
+
+//The output of chunk number counter, data being all of it: at most
+//blake3ChunkLen bytes, and only empty for an empty input
+func blake3Chunk(data []byte, counter uint64) blake3Output {
+	var o = blake3Output{cv: blake3IV, counter: counter, flags: blake3ChunkStart}
+	for len(data) > blake3BlockLen {
+		blake3Words(&o.block, data[:blake3BlockLen])
+		s := blake3Compress(&o.cv, &o.block, counter, blake3BlockLen, o.flags)
+		copy(o.cv[:], s[:8])
+		o.flags = 0
+		data = data[blake3BlockLen:]
+	}
+	blake3Words(&o.block, data)
+	o.blockLen = uint32(len(data))
+	o.flags |= blake3ChunkEnd
+	return o
+}
+
+//Little-endian words of a block, zero padded
+func blake3Words(block *[16]uint32, data []byte) {
+	var padded [blake3BlockLen]byte
+	copy(padded[:], data)
+	for i := range block {
+		block[i] = binary.LittleEndian.Uint32(padded[4*i:])
+	}
+}
+
+func blake3ParentOutput(left, right [8]uint32) blake3Output {
+	var o = blake3Output{cv: blake3IV, blockLen: blake3BlockLen, flags: blake3Parent}
+	copy(o.block[:8], left[:])
+	copy(o.block[8:], right[:])
+	return o
+}
+
+//Add the chaining value of chunk number total-1 to the stack of subtree
+//chaining values, merging every subtree it completes
+func blake3Push(stack [][8]uint32, cv [8]uint32, total uint64) [][8]uint32 {
+	for total&1 == 0 {
+		parent := blake3ParentOutput(stack[len(stack)-1], cv)
+		cv = parent.chainingValue()
+		stack = stack[:len(stack)-1]
+		total >>= 1
+	}
+	return append(stack, cv)
+}
+
+//BLAKE3 with its default 32 byte output, no key
+type blake3 struct {
+	stack   [][8]uint32
+	chunks  uint64
+	pending []byte
+}
+
+func newBlake3() hash.Hash {
+	return new(blake3)
+}
+
+func (d *blake3) Size() int      { return 32 }
+func (d *blake3) BlockSize() int { return blake3BlockLen }
+
+func (d *blake3) Reset() {
+	d.stack, d.chunks, d.pending = d.stack[:0], 0, d.pending[:0]
+}
+
+//Input is held back until there is more than a batch of it, the last
+//chunk always waiting for Sum since only then is it known whether it is the
+//root.
+func (d *blake3) Write(p []byte) (int, error) {
+	var threads = ThreadsPerFile
+	if threads < 1 {
+		threads = 1
+	}
+	var batch = blake3ChunkLen
+	if threads > 1 {
+		batch *= threads * blake3ChunksPerThread
+	}
+
+	var n = len(p)
+	for len(d.pending)+len(p) > batch {
+		if len(d.pending) == 0 {
+			d.hashChunks(p[:batch], threads)
+			p = p[batch:]
+			continue
+		}
+		var fill = batch - len(d.pending)
+		d.pending = append(d.pending, p[:fill]...)
+		p = p[fill:]
+		d.hashChunks(d.pending, threads)
+		d.pending = d.pending[:0]
+	}
+	d.pending = append(d.pending, p...)
+	return n, nil
+}
+
+//Hash whole chunks, split between threads goroutines, and add them to the
+//tree in order
+func (d *blake3) hashChunks(data []byte, threads int) {
+	var cvs = make([][8]uint32, len(data)/blake3ChunkLen)
+	var per = (len(cvs) + threads - 1) / threads
+	var wg sync.WaitGroup
+	for first := 0; first < len(cvs); first += per {
+		var last = first + per
+		if last > len(cvs) {
+			last = len(cvs)
+		}
+		wg.Add(1)
+		go func(first, last int) {
+			defer wg.Done()
+			for i := first; i < last; i++ {
+				o := blake3Chunk(data[i*blake3ChunkLen:(i+1)*blake3ChunkLen], d.chunks+uint64(i))
+				cvs[i] = o.chainingValue()
+			}
+		}(first, last)
+	}
+	wg.Wait()
+	for _, cv := range cvs {
+		d.chunks++
+		d.stack = blake3Push(d.stack, cv, d.chunks)
+	}
+}
+
+func (d *blake3) Sum(b []byte) []byte {
+	var stack = append([][8]uint32(nil), d.stack...)
+	var chunks = d.chunks
+	var data = d.pending
+	for len(data) > blake3ChunkLen {
+		o := blake3Chunk(data[:blake3ChunkLen], chunks)
+		chunks++
+		stack = blake3Push(stack, o.chainingValue(), chunks)
+		data = data[blake3ChunkLen:]
+	}
+
+	var o = blake3Chunk(data, chunks)
+	for i := len(stack) - 1; i >= 0; i-- {
+		o = blake3ParentOutput(stack[i], o.chainingValue())
+	}
+	return append(b, o.root()...)
+}
@@ -0,0 +1,110 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package hashes
+
+import (
+	"crypto/sha256"
+	"hash"
+)
+
+//Leaf block size of BitTorrent v2 merkle trees
+const btv2Block = 16 << 10
+
+//The pieces root of BitTorrent v2 (BEP 52): SHA-256 over each 16 KiB block,
+//the last one as short as the data, then a binary SHA-256 merkle tree over
+//those leaves, padded with leaves of 32 zero bytes to a power of two. Empty
+//data has no pieces root in a torrent; its sum here is 32 zero bytes.
+type btv2 struct {
+	leaves [][sha256.Size]byte
+	block  hash.Hash
+	filled int
+}
+
+func newBTv2() hash.Hash {
+	return &btv2{block: sha256.New()}
+}
+
+func (d *btv2) Size() int      { return sha256.Size }
+func (d *btv2) BlockSize() int { return btv2Block }
+
+func (d *btv2) Reset() {
+	d.leaves, d.filled = d.leaves[:0], 0
+	d.block.Reset()
+}
+
+func (d *btv2) Write(p []byte) (int, error) {
+	var n = len(p)
+	for len(p) > 0 {
+		var take = btv2Block - d.filled
+		if take > len(p) {
+			take = len(p)
+		}
+		d.block.Write(p[:take])
+		d.filled += take
+		p = p[take:]
+		if d.filled == btv2Block {
+			d.endBlock()
+		}
+	}
+	return n, nil
+}
+
+func (d *btv2) endBlock() {
+	var leaf [sha256.Size]byte
+	d.block.Sum(leaf[:0])
+	d.leaves = append(d.leaves, leaf)
+	d.block.Reset()
+	d.filled = 0
+}
+
+func (d *btv2) Sum(b []byte) []byte {
+	var layer = append([][sha256.Size]byte(nil), d.leaves...)
+	if d.filled > 0 {
+		var leaf [sha256.Size]byte
+		d.block.Sum(leaf[:0])
+		layer = append(layer, leaf)
+	}
+	if len(layer) == 0 {
+		return append(b, make([]byte, sha256.Size)...)
+	}
+
+	var width = 1
+	for width < len(layer) {
+		width *= 2
+	}
+	for len(layer) < width {
+		layer = append(layer, [sha256.Size]byte{})
+	}
+	for len(layer) > 1 {
+		var next = layer[:0]
+		for i := 0; i < len(layer); i += 2 {
+			next = append(next, sha256.Sum256(append(layer[i][:], layer[i+1][:]...)))
+		}
+		layer = next
+	}
+	return append(b, layer[0][:]...)
+}
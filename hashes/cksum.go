@@ -0,0 +1,90 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package hashes
+
+import (
+	"encoding/binary"
+	"hash"
+)
+
+//The CRC of POSIX cksum: polynomial 0x04C11DB7, most significant bit first,
+//starting from 0, over the data and then its length in as few bytes as it
+//takes, least significant first, complemented at the end
+var cksumTable = func() (table [256]uint32) {
+	for i := range table {
+		var crc = uint32(i) << 24
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04C11DB7
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return
+}()
+
+type cksum struct {
+	crc    uint32
+	length uint64
+}
+
+func newCksum() hash.Hash32 {
+	return new(cksum)
+}
+
+func (d *cksum) Size() int      { return 4 }
+func (d *cksum) BlockSize() int { return 1 }
+func (d *cksum) Reset()         { d.crc, d.length = 0, 0 }
+
+func (d *cksum) Write(p []byte) (int, error) {
+	d.crc = cksumUpdate(d.crc, p)
+	d.length += uint64(len(p))
+	return len(p), nil
+}
+
+func cksumUpdate(crc uint32, p []byte) uint32 {
+	for _, b := range p {
+		crc = crc<<8 ^ cksumTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+func (d *cksum) Sum32() uint32 {
+	var crc = d.crc
+	for n := d.length; n > 0; n >>= 8 {
+		crc = cksumUpdate(crc, []byte{byte(n)})
+	}
+	return ^crc
+}
+
+func (d *cksum) Sum(b []byte) []byte {
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], d.Sum32())
+	return append(b, sum[:]...)
+}
@@ -0,0 +1,103 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+//Package hashes creates the hash functions gohash knows how to compute.
+package hashes
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"sync"
+)
+
+//NewHasher returns a new hash.Hash computing the named algorithm, one of
+//blake3, btv2, cksum, crc32, md5, sha1, sha224, sha256, sha384 or sha512.
+//Write to it as data arrives and call Sum when done.
+func NewHasher(algo string) (hash.Hash, error) {
+	switch algo {
+	case "blake3":
+		return newBlake3(), nil
+	case "btv2":
+		return newBTv2(), nil
+	case "cksum":
+		return newCksum(), nil
+	case "crc32":
+		return crc32.NewIEEE(), nil
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha224":
+		return sha256.New224(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha384":
+		return sha512.New384(), nil
+	case "sha512":
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("I don't know how to compute a %s hash!", algo)
+}
+
+var pools = struct {
+	sync.Mutex
+	byAlgo map[string]*sync.Pool
+}{byAlgo: make(map[string]*sync.Pool)}
+
+//Borrow returns a hash.Hash computing algo, reusing one given back with
+//Return when there is one. It is always Reset and ready to use.
+func Borrow(algo string) (hash.Hash, error) {
+	pools.Lock()
+	pool, ok := pools.byAlgo[algo]
+	if !ok {
+		pool = new(sync.Pool)
+		pools.byAlgo[algo] = pool
+	}
+	pools.Unlock()
+
+	if h, ok := pool.Get().(hash.Hash); ok {
+		h.Reset()
+		return h, nil
+	}
+	return NewHasher(algo)
+}
+
+//Return gives h, computing algo, back for reuse by Borrow. h must not be
+//used again afterwards.
+func Return(algo string, h hash.Hash) {
+	pools.Lock()
+	pool := pools.byAlgo[algo]
+	pools.Unlock()
+
+	if pool != nil {
+		pool.Put(h)
+	}
+}
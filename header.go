@@ -0,0 +1,135 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+//Start of the line -header writes first, which tells -c how to read the
+//rest of the manifest:
+//
+//	# gohash-manifest v1 algo=<algo> format=<native|compat|cdc>
+//
+//algo is left out when several hashes were computed.
+const manifestHeaderPrefix = "# gohash-manifest "
+
+//1 when the check file begins with a header, which is not checked itself
+var headerLines int
+
+//Standard input for -c, buffered so the header can be read without taking
+//it away from openFilesForCheck
+var checkStdin = bufio.NewReader(os.Stdin)
+
+//The -header line for a manifest of algos, separated by commas
+func manifestHeader(algos string) string {
+	var header = manifestHeaderPrefix + "v1"
+	if !strings.Contains(algos, ",") {
+		header += " algo=" + algos
+	}
+	switch {
+	case *fCompat:
+		header += " format=compat"
+	case *fCDC:
+		header += " format=cdc"
+	default:
+		header += " format=native"
+	}
+	return header
+}
+
+//Configure -c from the header the check file begins with, if it has one.
+//The header decides the format and, unless -h was given, the algorithm of
+//compat lines; a flag that disagrees with it is an error.
+func readManifestHeader() error {
+	var r = checkStdin
+	if checkFileName() != "-" {
+		f, err := os.Open(checkFileName())
+		if err != nil {
+			//openFilesForCheck reports it
+			return nil
+		}
+		defer f.Close()
+		r = bufio.NewReader(f)
+	}
+
+	first, _ := r.Peek(len(manifestHeaderPrefix))
+	if string(first) != manifestHeaderPrefix {
+		return nil
+	}
+	//peeked rather than read, so stdin is left for openFilesForCheck
+	var line []byte
+	var ended bool
+	for n := len(manifestHeaderPrefix); n <= r.Size(); n++ {
+		b, err := r.Peek(n)
+		line = b
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			line, ended = b[:i], true
+			break
+		} else if err != nil {
+			ended = true
+			break
+		}
+	}
+	if !ended {
+		return fmt.Errorf("%s: the manifest header is longer than %d bytes", checkFileName(), r.Size())
+	}
+
+	headerLines = 1
+	var fields = strings.Fields(strings.TrimPrefix(string(line), manifestHeaderPrefix))
+	if len(fields) == 0 || fields[0] != "v1" {
+		return fmt.Errorf("%s: unsupported manifest header %q, this gohash reads v1", checkFileName(), strings.TrimSpace(string(line)))
+	}
+	for _, field := range fields[1:] {
+		var kv = strings.SplitN(field, "=", 2)
+		if len(kv) < 2 {
+			return fmt.Errorf("%s: manifest header field %q is not of the form key=value", checkFileName(), field)
+		}
+		switch kv[0] {
+		case "algo":
+			if !flagGiven("h") {
+				*fHash = kv[1]
+			}
+		case "format":
+			var compat, cdc = kv[1] == "compat", kv[1] == "cdc"
+			if !compat && !cdc && kv[1] != "native" {
+				return fmt.Errorf("%s: unknown manifest format %q", checkFileName(), kv[1])
+			}
+			if flagGiven("compat") && *fCompat != compat || flagGiven("cdc") && *fCDC != cdc {
+				return fmt.Errorf("%s: the manifest header says format=%s, which -compat or -cdc contradicts", checkFileName(), kv[1])
+			}
+			*fCompat, *fCDC = compat, cdc
+		default:
+			return fmt.Errorf("%s: unknown manifest header field %q", checkFileName(), kv[0])
+		}
+	}
+	return nil
+}
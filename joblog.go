@@ -0,0 +1,84 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+)
+
+//Where -joblog records each file once its hash has been printed
+var joblog *os.File
+
+//Open the -joblog for appending, so a restarted run adds to it
+func openJoblog(name string) error {
+	var err error
+	joblog, err = os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	return err
+}
+
+//Record that the hash of name has been printed
+func logDone(name string) {
+	if joblog != nil {
+		fmt.Fprintln(joblog, name)
+	}
+}
+
+//Drop the files a -skip-done joblog lists from names. The joblog not
+//existing yet means nothing is done.
+func skipDone(joblogName string, names []string) ([]string, error) {
+	f, err := os.Open(joblogName)
+	if os.IsNotExist(err) {
+		return names, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var done = make(map[string]bool)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		done[s.Text()] = true
+	}
+	if err := s.Err(); err != nil {
+		return nil, newHashError("read", joblogName, err)
+	}
+
+	var todo []string
+	for _, name := range names {
+		var file = name
+		if flag.NArg() > 0 {
+			_, file = argAlgo(name)
+		}
+		if !done[file] {
+			todo = append(todo, name)
+		}
+	}
+	return todo, nil
+}
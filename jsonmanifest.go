@@ -0,0 +1,96 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+//One entry of a JSON check file, which is an array of them
+type jsonEntry struct {
+	Path string `json:"path"`
+	Algo string `json:"algo"`
+	Hash string `json:"hash"`
+}
+
+//Whether the check file is JSON: its first byte other than whitespace is [
+func looksLikeJSON() bool {
+	var r = checkStdin
+	if checkFileName() != "-" {
+		f, err := os.Open(checkFileName())
+		if err != nil {
+			return false
+		}
+		defer f.Close()
+		r = bufio.NewReader(f)
+	}
+	for n := 1; n <= r.Size(); n++ {
+		b, err := r.Peek(n)
+		if len(b) < n {
+			return false
+		}
+		if c := b[n-1]; c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			return c == '['
+		}
+		if err != nil {
+			return false
+		}
+	}
+	return false
+}
+
+//Send each entry of a JSON check file to be checked the way a text line
+//would be, numbered from 0 in line. An entry that can't be decoded ends the
+//file, as nothing after it can be trusted to be where it seems.
+func readJSONManifest(in chan<- fileHash, r io.Reader) {
+	d := json.NewDecoder(r)
+	if t, err := d.Token(); err != nil || t != json.Delim('[') {
+		in <- fileHash{line: -1, err: newHashError("decode", checkFileName(), fmt.Errorf("a JSON check file is an array of {\"path\", \"algo\", \"hash\"} objects"))}
+		return
+	}
+	for line := 0; d.More(); line++ {
+		var e jsonEntry
+		if err := d.Decode(&e); err != nil {
+			in <- fileHash{line: -1, err: newHashError("decode", checkFileName(), fmt.Errorf("entry %d: %s", line+1, err.Error()))}
+			return
+		}
+		var name = strings.TrimPrefix(e.Path, *fPrefix)
+		if name == "" || e.Algo == "" || e.Hash == "" {
+			in <- fileHash{line: line, err: newHashError("decode", checkFileName(), fmt.Errorf("entry %d needs a path, algo and hash", line+1))}
+			continue
+		}
+		in <- checkListed(e.Algo, strings.ToLower(e.Hash), name, 0, line)
+	}
+	if _, err := d.Token(); err != nil {
+		in <- fileHash{line: -1, err: newHashError("decode", checkFileName(), fmt.Errorf("after the last entry: %s", err.Error()))}
+	}
+}
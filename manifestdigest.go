@@ -0,0 +1,110 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dietsche/gohash/hashes"
+)
+
+//Start of the -manifest-digest line, which ends a manifest:
+//
+//	# manifest-digest <algo> <hash>
+//
+//hash is algo over the binary hashes of every line before it, in order, so
+//a manifest with lines reordered, changed or cut off no longer matches it.
+//Only the hash column is covered, not names or anything else on the lines.
+const manifestDigestPrefix = "# manifest-digest "
+
+//The -manifest-digest line for sums
+func manifestDigestLine(label string, sums [][]byte) (string, error) {
+	algo, _ := gitBlob(label)
+	algo, _ = withMetadata(algo)
+	h, err := hashes.Borrow(algo)
+	if err != nil {
+		return "", err
+	}
+	defer hashes.Return(algo, h)
+	for _, sum := range sums {
+		h.Write(sum)
+	}
+	return fmt.Sprintf("%s%s %0x", manifestDigestPrefix, label, h.Sum(nil)), nil
+}
+
+//The hashes of the manifest lines in data, in order, up to its
+//-manifest-digest line, and that line. Lines that aren't hash lines are
+//passed over; they fail on their own when checked. trailing is whether
+//anything but blank lines comes after the digest line.
+func listedSums(data []byte) (sums [][]byte, digestLine string, trailing bool) {
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		if digestLine != "" {
+			trailing = trailing || strings.TrimSpace(s.Text()) != ""
+			continue
+		}
+		if strings.HasPrefix(s.Text(), manifestDigestPrefix) {
+			digestLine = s.Text()
+			continue
+		}
+		if algo, expected, _, ok := parseCheckLine(s.Text()); ok {
+			if algo == "crc32" {
+				expected = crcHex(expected)
+			}
+			if sum, err := hex.DecodeString(expected); err == nil {
+				sums = append(sums, sum)
+			}
+		}
+	}
+	return sums, digestLine, trailing
+}
+
+//Make sure the check file data ends in a -manifest-digest line that matches
+//the lines before it, with nothing after it that it doesn't cover
+func checkManifestDigest(data []byte) error {
+	sums, digestLine, trailing := listedSums(data)
+	if digestLine == "" {
+		return errors.New("no manifest digest line, the check file may have been cut off")
+	}
+	if trailing {
+		return errors.New("lines follow the manifest digest line, which only covers the lines before it")
+	}
+	var splits = strings.SplitN(strings.TrimPrefix(digestLine, manifestDigestPrefix), " ", 2)
+	want, err := manifestDigestLine(splits[0], sums)
+	if err != nil {
+		return err
+	}
+	if want != digestLine {
+		return errors.New("the manifest digest does not match, lines were reordered, changed or removed")
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+//One hash line of a manifest being merged, and where it came from
+type mergeEntry struct {
+	algo, hash, name string
+	from             string
+}
+
+//Combine the manifests names into one for -merge, each file and algorithm
+//once, sorted by name and then algorithm, written to stdout or -o. Two
+//manifests giving a file different hashes is a conflict, which fails the
+//merge unless -merge-prefer says which one wins. Returns the exit status.
+func merge(names []string) int {
+	var status int
+	var entries = make(map[string]*mergeEntry)
+	for _, name := range names {
+		if err := readMergeManifest(name, func(e mergeEntry) {
+			var key = e.algo + " " + e.name
+			var seen = entries[key]
+			switch {
+			case seen == nil:
+				entries[key] = &e
+			case seen.hash == e.hash, *fMergePrefer == "first":
+			case *fMergePrefer == "last":
+				entries[key] = &e
+			case *fMergePrefer == "":
+				fmt.Fprintf(os.Stderr, "conflict: %s has %s %s in %s but %s in %s\n", e.name, e.algo, seen.hash, seen.from, e.hash, e.from)
+				status = 1
+			}
+		}); err != nil {
+			printError(err)
+			status = 1
+		}
+	}
+	if status != 0 {
+		return status
+	}
+
+	var sorted = make([]*mergeEntry, 0, len(entries))
+	var algos = make(map[string]bool)
+	for _, e := range entries {
+		sorted = append(sorted, e)
+		algos[e.algo] = true
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].name != sorted[j].name {
+			return sorted[i].name < sorted[j].name
+		}
+		return sorted[i].algo < sorted[j].algo
+	})
+
+	var manifest bytes.Buffer
+	var w io.Writer = manifestWriter(os.Stdout)
+	if *fOutput != "" {
+		w = &manifest
+	}
+	if *fHeader {
+		var list []string
+		for algo := range algos {
+			list = append(list, algo)
+		}
+		sort.Strings(list)
+		fmt.Fprintln(w, manifestHeader(strings.Join(list, ",")))
+	}
+	for _, e := range sorted {
+		if *fCompat {
+			prefix, name := compatEscape(*fPrefix+e.name, false)
+			fmt.Fprintf(w, "%s%s  %s\n", prefix, e.hash, name)
+		} else {
+			fmt.Fprintf(w, "%s %s %s%s\n", e.algo, e.hash, *fPrefix, e.name)
+		}
+	}
+	if *fOutput != "" {
+		if err := writeManifest(*fOutput, manifest.Bytes()); err != nil {
+			printError(err)
+			return 1
+		}
+	}
+	return 0
+}
+
+//Read the hash lines of the manifest name, passing each to add in order.
+//A -header line at the top and a -manifest-digest line are passed over, as
+//the merged manifest gets new ones if any.
+func readMergeManifest(name string, add func(mergeEntry)) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for line := 0; s.Scan(); line++ {
+		if line == 0 && strings.HasPrefix(s.Text(), manifestHeaderPrefix) || strings.HasPrefix(s.Text(), manifestDigestPrefix) {
+			continue
+		}
+		algo, hash, file, ok := parseCheckLine(s.Text())
+		if !ok {
+			return newHashError("decode", name, fmt.Errorf("line %d is not of the form: hash value filename", line+1))
+		}
+		add(mergeEntry{algo, strings.ToLower(hash), file, name})
+	}
+	if err := s.Err(); err != nil {
+		return newHashError("read", name, err)
+	}
+	return nil
+}
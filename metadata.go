@@ -0,0 +1,63 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"strings"
+)
+
+//Split a -with-metadata label such as meta-sha256 into the algorithm. Such
+//hashes cover the file's metadata as well as its contents.
+func withMetadata(label string) (algo string, ok bool) {
+	if strings.HasPrefix(label, "meta-") {
+		return strings.TrimPrefix(label, "meta-"), true
+	}
+	return label, false
+}
+
+//The metadata -with-metadata hashes ahead of the contents of file, in this
+//order: the size as 8 bytes big-endian, the permission bits as 4 bytes
+//big-endian, and the modification time in whole seconds since 1970 as 8
+//bytes big-endian. Seconds, as that is what survives copying to most
+//filesystems and archive formats.
+func metadataFraming(file fileHash) ([]byte, error) {
+	if file.fileName == nil || file.size < 0 {
+		return nil, newHashError("hash", file.displayName(), errors.New("-with-metadata needs a file on disk, which stdin and pipes aren't"))
+	}
+	fi, err := os.Stat(*file.fileName)
+	if err != nil {
+		return nil, newHashError("hash", file.displayName(), err)
+	}
+	var meta [20]byte
+	binary.BigEndian.PutUint64(meta[0:8], uint64(file.size))
+	binary.BigEndian.PutUint32(meta[8:12], uint32(fi.Mode().Perm()))
+	binary.BigEndian.PutUint64(meta[12:20], uint64(fi.ModTime().Unix()))
+	return meta[:], nil
+}
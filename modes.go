@@ -0,0 +1,144 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+//What each mode or option can't be combined with, by its flag without the
+//dash. A flag counts as given when its value isn't false, empty or 0, so a
+//new flag only needs an entry here, and the entries of the modes it can't
+//work with, to be checked.
+var modeRules = []struct {
+	flag       string
+	does       string   //what it does, to say why in the message
+	not        []string //flags it doesn't work with
+	oneHash    bool     //a single algorithm in -h
+	needsFiles bool     //FILE arguments rather than stdin
+}{
+	{flag: "pairs", does: "takes the place of the check file of -c", not: []string{"compat", "cdc", "fix", "json-manifest", "manifest-digest", "verify-permissions", "strict-permissions"}, needsFiles: true},
+	{flag: "json-manifest", does: "reads a check file of its own", not: []string{"compat", "cdc", "fix", "manifest-digest", "verify-permissions"}},
+	{flag: "cdc", does: "writes a manifest of chunks", not: []string{"compat", "fix"}, oneHash: true},
+	{flag: "raw-out", does: "writes the binary hash of one FILE or stdin", not: []string{"c", "cdc"}, oneHash: true},
+	{flag: "ads", does: "stores the hashes of files", not: []string{"c", "cdc", "verify-name", "compare-to", "follow", "concat", "structure", "go-sum"}},
+	{flag: "canonicalize", does: "hashes documents one at a time", not: []string{"structure", "go-sum", "concat", "parallel-segments", "follow"}},
+	{flag: "ignore-metadata-fields", does: "hashes files one at a time", not: []string{"canonicalize", "structure", "go-sum", "concat", "parallel-segments", "follow"}},
+	{flag: "raw-dir", does: "is for hashing", not: []string{"c", "cdc", "verify-name", "compare-to", "follow"}},
+	{flag: "expect", does: "checks one hash of one FILE or stdin", not: []string{"c", "cdc", "go-sum", "fingerprint"}, oneHash: true},
+	{flag: "split-by-algo", does: "writes hashes", not: []string{"c", "o", "parallel-segments"}},
+	{flag: "verify-permissions", does: "needs the usual output", not: []string{"compat", "cdc", "go-sum", "fingerprint", "format", "no-filename"}},
+	{flag: "verify-owner", does: "needs the usual output", not: []string{"compat", "cdc", "go-sum", "fingerprint", "format", "no-filename", "json-manifest", "pairs", "merge", "emit-script"}},
+	{flag: "compare-to", does: "reports on the FILEs against a manifest", not: []string{"c", "cdc", "concat", "only-changed"}, oneHash: true},
+	{flag: "joblog", does: "records hashes as they are printed", not: []string{"c", "o", "split-by-algo", "concat", "compare-to"}},
+	{flag: "dupe-index", does: "records the files hashed", not: []string{"c", "cdc", "concat", "structure", "go-sum", "follow", "merge", "verify-name", "compare-to", "detect", "repeat", "count-only"}},
+	{flag: "cache", does: "only holds plain hashes of files", not: []string{"cdc", "concat", "structure", "go-sum", "external", "domain", "bind-path", "length-prefix", "strip-bom", "canonicalize", "ignore-metadata-fields", "with-metadata"}},
+	{flag: "verify-name", does: "checks the FILEs given against their names", not: []string{"c", "compat", "cdc", "fix", "concat", "structure", "go-sum", "external", "parallel-segments", "git-blob", "compare-to", "o", "follow"}, oneHash: true, needsFiles: true},
+	{flag: "merge", does: "combines manifest FILEs", not: []string{"c", "cdc", "verify-permissions", "manifest-digest", "follow", "verify-name"}, needsFiles: true},
+	{flag: "header", does: "is for hash lines -c can read", not: []string{"c", "go-sum", "fingerprint", "format", "no-filename", "split-by-algo", "compare-to"}},
+	{flag: "manifest-digest", does: "needs the usual hash lines", not: []string{"compat", "cdc", "go-sum", "fingerprint", "format", "no-filename", "split-by-algo", "compare-to"}},
+	{flag: "sort", does: "orders hash output", not: []string{"c", "verify-name", "compare-to", "follow", "concat"}},
+	{flag: "o", does: "writes hashes", not: []string{"c"}},
+	{flag: "no-filename", does: "is for printing hashes", not: []string{"c", "verify-name"}},
+	{flag: "follow", does: "reads one FILE as it grows", not: []string{"c", "compat", "cdc", "concat", "structure", "go-sum", "external", "parallel-segments"}, oneHash: true},
+	{flag: "emit-script", does: "checks named files as sha256sum and the like see them", not: []string{"c", "compat", "cdc", "concat", "structure", "go-sum", "external", "parallel-segments", "follow", "git-blob", "with-metadata", "fingerprint", "format", "no-filename", "verify-permissions", "header", "manifest-digest", "split-by-algo", "domain", "bind-path", "length-prefix", "strip-bom", "canonicalize", "ignore-metadata-fields"}, needsFiles: true},
+	{flag: "count-only", does: "only counts what would be hashed", not: []string{"c", "cdc", "follow", "merge", "verify-name", "compare-to", "detect", "repeat", "o", "split-by-algo", "raw-dir", "manifest-digest"}},
+	{flag: "repeat", does: "times hashing the FILEs given", not: []string{"c", "compat", "cdc", "concat", "structure", "go-sum", "external", "parallel-segments", "follow", "merge", "verify-name", "compare-to", "detect", "checkpoint", "git-blob", "with-metadata"}, needsFiles: true},
+	{flag: "detect", does: "tries every algorithm itself against the FILEs given", not: []string{"c", "compat", "cdc", "concat", "structure", "go-sum", "external", "parallel-segments", "follow", "merge", "verify-name", "compare-to", "checkpoint", "git-blob"}, needsFiles: true},
+	{flag: "summary-json", does: "sums up hashing or checking files", not: []string{"follow", "merge", "repeat", "detect"}},
+	{flag: "summary-file", does: "sums up hashing or checking files", not: []string{"follow", "merge", "repeat", "detect"}},
+	{flag: "format", does: "is for hash output", not: []string{"c", "cdc"}},
+	{flag: "fingerprint", does: "is for reading", not: []string{"c", "compat", "cdc"}},
+	{flag: "go-sum", does: "hashes FILEs as go.sum does", not: []string{"c", "compat", "cdc", "concat", "structure", "domain", "bind-path", "length-prefix", "strip-bom"}, needsFiles: true},
+	{flag: "git-blob", does: "hashes files as git stores them", not: []string{"compat", "cdc", "concat", "structure", "go-sum", "external", "parallel-segments", "follow", "length-prefix", "bind-path", "domain", "strip-bom"}},
+	{flag: "with-metadata", does: "labels its hashes", not: []string{"compat", "cdc", "concat", "structure", "go-sum", "external", "parallel-segments", "follow", "git-blob", "cache", "verify-name", "detect"}},
+	{flag: "parallel-segments", does: "hashes segments of each file on their own", not: []string{"compat", "cdc", "concat", "structure", "go-sum", "external", "checkpoint", "length-prefix", "bind-path", "domain", "strip-bom"}, oneHash: true},
+	{flag: "newer-than", does: "chooses among the FILEs to hash", not: []string{"c", "verify-name", "follow"}},
+	{flag: "older-than", does: "chooses among the FILEs to hash", not: []string{"c", "verify-name", "follow"}},
+}
+
+//Flags that are only of use with another
+var flagNeeds = []struct{ flag, needs string }{
+	{"pairs", "c"},
+	{"json-manifest", "c"},
+	{"no-extra", "c"},
+	{"cache-ignore-mtime", "cache"},
+	{"raw-dir-mirror", "raw-dir"},
+	{"detect-all", "detect"},
+	{"merge-prefer", "merge"},
+	{"silent", "status"},
+}
+
+//Whether the named flag is turned on, by the command line, -config or a
+//manifest header
+func flagOn(name string) bool {
+	switch flag.Lookup(name).Value.String() {
+	case "", "false", "0":
+		return false
+	}
+	return true
+}
+
+//The first combination of flags in modeRules or flagNeeds that was given
+func checkModes() error {
+	for _, rule := range modeRules {
+		if !flagOn(rule.flag) {
+			continue
+		}
+		var given []string
+		for _, other := range rule.not {
+			if flagOn(other) {
+				given = append(given, "-"+other)
+			}
+		}
+		if len(given) > 0 {
+			return fmt.Errorf("-%s %s, so not with %s.", rule.flag, rule.does, orList(given))
+		}
+		if rule.oneHash && strings.Contains(*fHash, ",") {
+			return fmt.Errorf("-%s works with one hash.", rule.flag)
+		}
+		if rule.needsFiles && flag.NArg() == 0 {
+			return fmt.Errorf("-%s needs FILEs to work on.", rule.flag)
+		}
+	}
+	for _, need := range flagNeeds {
+		if flagOn(need.flag) && !flagOn(need.needs) {
+			return fmt.Errorf("-%s needs -%s.", need.flag, need.needs)
+		}
+	}
+	return nil
+}
+
+//"a", "a or b", "a, b or c"
+func orList(items []string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " or " + items[len(items)-1]
+}
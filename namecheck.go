@@ -0,0 +1,112 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dietsche/gohash/hashes"
+)
+
+//Algorithms by the number of hex digits their hashes have, for telling
+//which one a -verify-name file is named with. blake3 and sha256 hashes are
+//the same length; sha256 is the usual one.
+var algoByDigits = map[int]string{
+	8:   "crc32",
+	32:  "md5",
+	40:  "sha1",
+	56:  "sha224",
+	64:  "sha256",
+	96:  "sha384",
+	128: "sha512",
+}
+
+//The algorithms of the coreutils tools, md5sum through sha512sum, by the
+//number of hex digits their hashes have. No two are the same length.
+var coreutilsByDigits = map[int]string{
+	32:  "md5",
+	40:  "sha1",
+	56:  "sha224",
+	64:  "sha256",
+	96:  "sha384",
+	128: "sha512",
+}
+
+//Set by handleFlags when checking coreutils style lines, which have no
+//algorithm column, without -h: each line's algorithm is then told by the
+//length of its hash
+var inferAlgo bool
+
+//The coreutils algorithm whose hashes are as long as expected, or -h when
+//there is none
+func algoOf(expected string) string {
+	if algo, ok := coreutilsByDigits[len(expected)]; ok {
+		return algo
+	}
+	return *fHash
+}
+
+//Check each FILE against the hash its name starts with, for content
+//addressed stores. The hash runs up to the first dot of the base name, so
+//both <hash> and <hash>.blob work. Results go to reportCheckResults as if
+//the names had come from a check file, one line per FILE.
+func openFilesForNames(in chan<- fileHash) {
+	defer close(in)
+
+	for line, name := range flag.Args() {
+		var name = name
+		var expected = strings.ToLower(strings.SplitN(filepath.Base(name), ".", 2)[0])
+		var algo = *fHash
+		if !flagGiven("h") {
+			algo = algoByDigits[len(expected)]
+		}
+		if !isHashOf(algo, expected) {
+			var err = errors.New("the name does not start with a hash gohash knows")
+			if flagGiven("h") {
+				err = fmt.Errorf("the name does not start with a hash computed by %s", algo)
+			}
+			in <- fileHash{fileName: &name, line: line, err: newHashError("decode", name, err)}
+			continue
+		}
+		in <- openListed(fileHash{expectedHashType: &algo, expecteHash: &expected, line: line}, name)
+	}
+}
+
+//Whether expected, in hex, is the length of an algo hash
+func isHashOf(algo, expected string) bool {
+	h, err := hashes.NewHasher(algo)
+	if err != nil {
+		return false
+	}
+	_, err = hex.DecodeString(expected)
+	return err == nil && len(expected) == 2*h.Size()
+}
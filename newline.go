@@ -0,0 +1,91 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"io"
+)
+
+//A manifest as written, without its last newline with -final-newline=false
+func finalNewline(data []byte) []byte {
+	if *fFinalNewline {
+		return data
+	}
+	return bytes.TrimSuffix(data, []byte("\n"))
+}
+
+//Where to print a manifest going to w, leaving off its last newline with
+//-final-newline=false
+func manifestWriter(w io.Writer) io.Writer {
+	if *fFinalNewline {
+		return w
+	}
+	return &heldNewline{w: w}
+}
+
+//Writes to w all but a trailing newline, which is only written once more
+//follows it
+type heldNewline struct {
+	w    io.Writer
+	held bool
+}
+
+func (h *heldNewline) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if h.held {
+		if _, err := h.w.Write([]byte("\n")); err != nil {
+			return 0, err
+		}
+		h.held = false
+	}
+	var data = p
+	if p[len(p)-1] == '\n' {
+		data, h.held = p[:len(p)-1], true
+	}
+	if _, err := h.w.Write(data); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+//Remembers the last byte read through it, for -strict-newline to tell
+//whether the check file ended with a newline
+type lastByteReader struct {
+	r    io.Reader
+	last int
+}
+
+func (l *lastByteReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	if n > 0 {
+		l.last = int(p[n-1])
+	}
+	return n, err
+}
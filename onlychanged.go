@@ -0,0 +1,83 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"os"
+)
+
+//Read the hashes in manifest that can be reused by -only-changed. A hash is
+//reused when it was computed with -h and its file has not been modified
+//since the manifest was. Manifests record no sizes or times of their own,
+//so the manifest's modification time is the reference.
+//
+//names is returned unchanged, or when it is empty, replaced by the files the
+//manifest lists in order.
+func unchangedHashes(manifest string, names []string) ([]string, map[string][]byte, error) {
+	f, err := os.Open(manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var listed = len(names) == 0
+	var reuse = make(map[string][]byte)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		algo, expected, name, ok := parseCheckLine(s.Text())
+		if ok && *fVerifyPermissions {
+			_, name, ok = splitMode(name)
+		}
+		if ok && *fVerifyOwner {
+			_, name, ok = splitOwner(name)
+		}
+		if !ok {
+			continue
+		}
+		if listed {
+			names = append(names, name)
+		}
+
+		hash, err := hex.DecodeString(expected)
+		if algo != *fHash || err != nil {
+			continue
+		}
+		if cur, err := os.Stat(name); err == nil && cur.Mode().IsRegular() && !cur.ModTime().After(fi.ModTime()) {
+			reuse[name] = hash
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, nil, newHashError("read", manifest, err)
+	}
+	return names, reuse, nil
+}
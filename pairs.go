@@ -0,0 +1,64 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+//A file to check and the hash expected of it, given on the command line
+//with -pairs
+type pair struct {
+	algo, hash, name string
+}
+
+//Set by handleFlags from the arguments when -pairs was given
+var pairs []pair
+
+//Parse -pairs arguments of the form algorithm=hash=file. The file name may
+//itself hold = signs.
+func parsePairs(args []string) ([]pair, error) {
+	var parsed []pair
+	for i, arg := range args {
+		var splits = strings.SplitN(arg, "=", 3)
+		if len(splits) < 3 || splits[0] == "" || splits[1] == "" || splits[2] == "" {
+			return nil, fmt.Errorf("argument %d, %q, is not of the form algorithm=hash=file", i+1, arg)
+		}
+		parsed = append(parsed, pair{strings.ToLower(splits[0]), strings.ToLower(splits[1]), splits[2]})
+	}
+	return parsed, nil
+}
+
+//Check the files named by -pairs as if they were the lines of a check file
+func openFilesForPairs(in chan<- fileHash) {
+	defer close(in)
+
+	for line, p := range pairs {
+		in <- checkListed(p.algo, p.hash, p.name, 0, line)
+	}
+}
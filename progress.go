@@ -0,0 +1,173 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+//Progress events are at most this often, apart from the last one
+const progressInterval = 250 * time.Millisecond
+
+//How much each new measure of the read rate moves the one the ETA is worked
+//out from. Rates over a quarter second jump about, so each counts for
+//little on its own.
+const rateSmoothing = 0.2
+
+//Measures of the rate thrown away at the start, while caches fill and
+//buffers are keeping up more than the disk is
+const rateWarmup = 4
+
+//One line of -progress-json. The last one has event "done". The ETA, in
+//seconds, is left out until the rate has settled.
+type progressEvent struct {
+	Event   string  `json:"event"`
+	File    string  `json:"file,omitempty"`
+	Done    int64   `json:"bytes_done"`
+	Total   int64   `json:"bytes_total"`
+	Percent float64 `json:"percent"`
+	ETA     int64   `json:"eta_seconds,omitempty"`
+}
+
+//Bytes hashed so far out of those there are to hash, for -progress-json
+type progress struct {
+	sync.Mutex
+	enc   *json.Encoder
+	done  int64
+	total int64
+	file  string
+	last  time.Time
+
+	//bytes done at the last event, and the smoothed bytes a second since,
+	//from this many measures including those of the warm-up
+	lastDone int64
+	rate     float64
+	samples  int
+}
+
+//Set by handleFlags when -progress-json was given
+var prog *progress
+
+//Start -progress-json, writing to w. When hashing, the total is the size of
+//the FILEs given; in check mode it grows as the check file names files.
+func startProgress(w io.Writer) *progress {
+	p := &progress{enc: json.NewEncoder(w)}
+	if !*fCheck {
+		for _, arg := range flag.Args() {
+			_, name := argAlgo(arg)
+			if fi, err := os.Stat(name); err == nil && fi.Mode().IsRegular() {
+				p.total += fi.Size()
+			}
+		}
+	}
+	return p
+}
+
+//Add the size of a file that is going to be hashed to the total
+func (p *progress) grow(size int64) {
+	if p == nil || size < 0 {
+		return
+	}
+	p.Lock()
+	p.total += size
+	p.Unlock()
+}
+
+func (p *progress) add(file string, n int) {
+	p.Lock()
+	defer p.Unlock()
+	p.done += int64(n)
+	p.file = file
+	if time.Since(p.last) >= progressInterval {
+		var now = time.Now()
+		if !p.last.IsZero() {
+			var rate = float64(p.done-p.lastDone) / now.Sub(p.last).Seconds()
+			if p.samples == rateWarmup {
+				p.rate = rate
+			} else if p.samples > rateWarmup {
+				p.rate += rateSmoothing * (rate - p.rate)
+			}
+			p.samples++
+		}
+		p.last, p.lastDone = now, p.done
+		p.emit("progress")
+	}
+}
+
+//Write the final event
+func (p *progress) finish() {
+	if p == nil {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	p.file = ""
+	p.emit("done")
+}
+
+func (p *progress) emit(event string) {
+	var e = progressEvent{Event: event, File: p.file, Done: p.done, Total: p.total}
+	if p.total > 0 {
+		e.Percent = float64(p.done) * 100 / float64(p.total)
+		if e.Percent > 100 {
+			//the total is only what was known ahead
+			e.Percent = 100
+		}
+	}
+	if event == "progress" && p.samples > rateWarmup && p.rate > 0 && p.total > p.done {
+		e.ETA = int64(math.Ceil(float64(p.total-p.done) / p.rate))
+	}
+	p.enc.Encode(e)
+}
+
+//Copy r to w like io.Copy, but with -chunk-size reading at most that much
+//at a time, so progressReader sees reads of a predictable size
+func copyChunked(w io.Writer, r io.Reader) (int64, error) {
+	if *fChunkSize == 0 {
+		return io.Copy(w, r)
+	}
+	//hide any WriteTo, which would bypass the buffer
+	return io.CopyBuffer(w, struct{ io.Reader }{r}, make([]byte, *fChunkSize))
+}
+
+//Reports what is read from a file to prog
+type progressReader struct {
+	io.ReadCloser
+	name string
+}
+
+func (r progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	prog.add(r.name, n)
+	return n, err
+}
@@ -0,0 +1,92 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+//Hash each FILE n times for -repeat, opening it afresh every time, and print
+//how long the runs took:
+//
+//	<name>: <n> runs of <bytes> bytes, min <time>, max <time>, mean <time>
+//
+//The first run usually reads from disk and the rest from the page cache, so
+//the min and mean show steady-state throughput. Returns the exit status.
+func repeat(n int, names []string) int {
+	status := 0
+	for _, name := range names {
+		var name = name
+		var min, max, total time.Duration
+		var size int64
+		var err error
+		for i := 0; i < n && err == nil; i++ {
+			var took time.Duration
+			took, size, err = timeDigest(name)
+			if i == 0 || took < min {
+				min = took
+			}
+			if took > max {
+				max = took
+			}
+			total += took
+		}
+		if err != nil {
+			printError(err)
+			status = 1
+			continue
+		}
+		fmt.Printf("%s: %d runs of %d bytes, min %s, max %s, mean %s\n", name, n, size, min, max, total/time.Duration(n))
+	}
+	return status
+}
+
+//Open name and hash it with -h, returning how long that took and how many
+//bytes were read
+func timeDigest(name string) (time.Duration, int64, error) {
+	var start = time.Now()
+	f, _, err := openFile(name)
+	if err != nil {
+		return 0, 0, newHashError("open", name, err)
+	}
+	defer f.Close()
+
+	var counter = &countingReader{ReadCloser: f}
+	var r io.ReadCloser = counter
+	if throttle != nil {
+		r = &throttledReader{r, throttle}
+	}
+	if ratePerFile > 0 {
+		r = &throttledReader{r, newLimiter(ratePerFile)}
+	}
+	if _, _, err := digest(fileHash{fileName: &name, r: r, expectedHashType: fHash}); err != nil {
+		return 0, 0, err
+	}
+	return time.Since(start), counter.n, nil
+}
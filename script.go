@@ -0,0 +1,67 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+//Algorithms -emit-script can check, each with its coreutils tool <algo>sum
+var scriptAlgos = map[string]bool{"md5": true, "sha1": true, "sha224": true, "sha256": true, "sha384": true, "sha512": true}
+
+//The start of an -emit-script script, up to the first file it checks
+const scriptHeader = `#!/bin/sh
+# Checks the files below against the hashes gohash gave them, using the
+# sha256sum family of tools. Run it from where gohash was run.
+failed=0
+check() {
+	if [ "$({ "${1}sum" < "$3" | cut -d ' ' -f 1; } 2>/dev/null)" = "$2" ]; then
+		printf '%s: OK\n' "$3"
+	else
+		printf '%s: FAILED\n' "$3"
+		failed=$((failed + 1))
+	fi
+}
+`
+
+//The end of an -emit-script script, after the last file it checks
+const scriptFooter = `if [ "$failed" -ne 0 ]; then
+	printf 'WARNING: %d computed checksums did NOT match\n' "$failed" >&2
+	exit 1
+fi
+`
+
+//The line of an -emit-script script that checks name against sum
+func scriptLine(algo string, sum []byte, name string) string {
+	return fmt.Sprintf("check %s %0x %s\n", algo, sum, shellQuote(name))
+}
+
+//Quote s for sh, so that the shell takes it as it is
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
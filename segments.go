@@ -0,0 +1,127 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dietsche/gohash/hashes"
+)
+
+//Split a -parallel-segments label such as sha256/4 into the algorithm and
+//the number of segments
+func segmented(label string) (algo string, n int, ok bool) {
+	var i = strings.LastIndex(label, "/")
+	if i < 0 {
+		return label, 0, false
+	}
+	n, err := strconv.Atoi(label[i+1:])
+	if err != nil || n < 1 {
+		return label, 0, false
+	}
+	return label[:i], n, true
+}
+
+//Hash file in n segments at once for -parallel-segments. The file is split
+//into n ranges of ceil(size/n) bytes, the last ones shorter or empty, and
+//each range is hashed with algo. The result is algo over
+//
+//	<size as 8 bytes big-endian> <hash of range 1> ... <hash of range n>
+//
+//so it differs from the plain hash of the file, and is labeled algo/n.
+func segmentDigest(file fileHash, algo string, n int) ([]byte, int64, error) {
+	f, ok := file.r.(*os.File)
+	if !ok || file.size < 0 {
+		return nil, 0, newHashError("hash", file.displayName(), errors.New("-parallel-segments needs a regular file"))
+	}
+
+	//the segments of one file share its -limit-rate-per-file
+	var perFile *limiter
+	if ratePerFile > 0 {
+		perFile = newLimiter(ratePerFile)
+	}
+
+	var length = (file.size + int64(n) - 1) / int64(n)
+	var sums = make([][]byte, n)
+	var errs = make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		var offset = int64(i) * length
+		if offset > file.size {
+			offset = file.size
+		}
+		var end = offset + length
+		if end > file.size {
+			end = file.size
+		}
+
+		wg.Add(1)
+		go func(i int, r io.Reader) {
+			defer wg.Done()
+			h, err := hashes.Borrow(algo)
+			if err != nil {
+				errs[i] = newHashError("hash", file.displayName(), err)
+				return
+			}
+			defer hashes.Return(algo, h)
+			if throttle != nil {
+				r = &throttledReader{ioutil.NopCloser(r), throttle}
+			}
+			if perFile != nil {
+				r = &throttledReader{ioutil.NopCloser(r), perFile}
+			}
+			if _, err = io.Copy(h, r); err != nil {
+				errs[i] = readError(file.displayName(), err)
+				return
+			}
+			sums[i] = h.Sum(nil)
+		}(i, io.NewSectionReader(f, offset, end-offset))
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	h, _ := hashes.Borrow(algo)
+	defer hashes.Return(algo, h)
+	var size [8]byte
+	binary.BigEndian.PutUint64(size[:], uint64(file.size))
+	h.Write(size[:])
+	for _, sum := range sums {
+		h.Write(sum)
+	}
+	return h.Sum(nil), file.size, nil
+}
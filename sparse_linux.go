@@ -0,0 +1,113 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+//whence values for lseek from linux/fs.h
+const (
+	seekData = 3
+	seekHole = 4
+)
+
+//Reads a file like an ordinary *os.File, but returns the zeros of holes
+//without reading them from disk. Only size bytes are read.
+type sparseReader struct {
+	*os.File
+	size int64
+	pos  int64
+	data int64 //next data region starts here, bytes before it are a hole
+	hole int64 //and ends here
+}
+
+//Read f skipping its holes. File systems that don't report holes are read
+//normally.
+func sparseFile(f *os.File, size int64) io.ReadCloser {
+	if size <= 0 {
+		return f
+	}
+	return &sparseReader{File: f, size: size}
+}
+
+func (s *sparseReader) Read(p []byte) (int, error) {
+	if s.pos >= s.size {
+		return 0, io.EOF
+	}
+
+	if s.pos >= s.hole {
+		data, err := s.File.Seek(s.pos, seekData)
+		if err == nil {
+			s.data = data
+			s.hole, err = s.File.Seek(data, seekHole)
+		} else if isErrno(err, syscall.ENXIO) {
+			//nothing but a hole from here to the end
+			s.data, s.hole, err = s.size, s.size, nil
+		} else if isErrno(err, syscall.EINVAL) {
+			//holes aren't supported here, treat the rest as data
+			s.data, s.hole, err = s.pos, s.size, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if s.hole > s.size {
+			s.hole = s.size
+		}
+	}
+
+	if s.pos < s.data {
+		n := len(p)
+		if int64(n) > s.data-s.pos {
+			n = int(s.data - s.pos)
+		}
+		for i := range p[:n] {
+			p[i] = 0
+		}
+		s.pos += int64(n)
+		return n, nil
+	}
+
+	if int64(len(p)) > s.hole-s.pos {
+		p = p[:s.hole-s.pos]
+	}
+	n, err := s.File.ReadAt(p, s.pos)
+	s.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func isErrno(err error, errno syscall.Errno) bool {
+	if pe, ok := err.(*os.PathError); ok {
+		err = pe.Err
+	}
+	return err == errno
+}
@@ -0,0 +1,70 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+//A fingerprint of the layout of a directory tree rather than its contents,
+//for -structure. Every file and directory under root, root included, is
+//listed in lexical order (the order of filepath.Walk) as one line:
+//
+//	<mode> <size> <path>\n
+//
+//mode is the os.FileMode string such as -rw-r--r-- or drwxr-xr-x, size is
+//the size in bytes (always 0 for directories), and path is relative to root
+//with / separators, root itself being ".". Symbolic links are listed, not
+//followed. Modification times and ownership are not part of the listing.
+func structureReader(root string) (io.ReadCloser, error) {
+	if _, err := os.Lstat(root); err != nil {
+		return nil, err
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return newHashError("read", path, err)
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			var size int64
+			if !info.IsDir() {
+				size = info.Size()
+			}
+			_, err = fmt.Fprintf(w, "%s %d %s\n", info.Mode(), size, filepath.ToSlash(rel))
+			return err
+		}))
+	}()
+	return r, nil
+}
@@ -0,0 +1,95 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+//What happened during the run, for -summary-json
+type runSummary struct {
+	Mode       string   `json:"mode"`
+	Algorithms []string `json:"algorithms"`
+	Succeeded  int      `json:"succeeded"`
+	Failed     int      `json:"failed"`
+	Skipped    int      `json:"skipped"`
+	Bytes      int64    `json:"bytes"`
+	Elapsed    float64  `json:"elapsed_seconds"`
+
+	start time.Time
+}
+
+var summary = runSummary{start: time.Now()}
+
+//Count a file that was hashed
+func (s *runSummary) add(file fileHash) {
+	s.Succeeded++
+	s.Bytes += file.bytes
+	for _, algo := range file.algos {
+		s.addAlgorithm(algo)
+	}
+}
+
+func (s *runSummary) addAlgorithm(algo string) {
+	for _, a := range s.Algorithms {
+		if a == algo {
+			return
+		}
+	}
+	s.Algorithms = append(s.Algorithms, algo)
+}
+
+//Write the summary once the pipeline has drained, if it was asked for
+func writeSummary() {
+	if !*fSummaryJSON && *fSummaryFile == "" {
+		return
+	}
+
+	summary.Mode = "hash"
+	if *fCheck || *fVerifyName {
+		summary.Mode = "check"
+	}
+	if summary.Algorithms == nil {
+		summary.Algorithms = []string{}
+	}
+	summary.Elapsed = time.Since(summary.start).Seconds()
+
+	data, err := json.Marshal(&summary)
+	if err != nil {
+		printError(err)
+		return
+	}
+	data = append(data, '\n')
+
+	if *fSummaryFile == "" {
+		os.Stderr.Write(data)
+	} else if err := writeFileAtomic(*fSummaryFile, data, 0644); err != nil {
+		printError(err)
+	}
+}
@@ -0,0 +1,59 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import "log/syslog"
+
+var syslogWriter *syslog.Writer
+
+//Connect to the system log for -syslog
+func openSyslog() error {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_USER, "gohash")
+	if err != nil {
+		return err
+	}
+	syslogWriter = w
+	return nil
+}
+
+//Send a verification result to the system log, if -syslog connected to it
+func logResult(level resultLevel, msg string) {
+	if syslogWriter == nil {
+		return
+	}
+	switch level {
+	case resultOK:
+		syslogWriter.Info(msg)
+	case resultFailed:
+		syslogWriter.Warning(msg)
+	default:
+		syslogWriter.Err(msg)
+	}
+}
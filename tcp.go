@@ -0,0 +1,59 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"net"
+	"strings"
+	"time"
+)
+
+//Inputs named tcp://host:port are read from a connection to host:port, to
+//its end when the other side closes it
+const tcpPrefix = "tcp://"
+
+//Connect to the tcp:// input name. With -tcp-timeout, connecting and each
+//read give up after that long.
+func dialStream(name string) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", strings.TrimPrefix(name, tcpPrefix), *fTCPTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return idleConn{conn}, nil
+}
+
+//A connection whose reads time out after -tcp-timeout without data
+type idleConn struct {
+	net.Conn
+}
+
+func (c idleConn) Read(p []byte) (int, error) {
+	if *fTCPTimeout > 0 {
+		c.SetReadDeadline(time.Now().Add(*fTCPTimeout))
+	}
+	return c.Conn.Read(p)
+}
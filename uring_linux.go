@@ -0,0 +1,246 @@
+//go:build uring
+// +build uring
+
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+//One io_uring shared by every digester. Reads from all open files are
+//handed to a single goroutine, which submits everything waiting in one
+//io_uring_enter call, so hashing many small files makes far fewer syscalls.
+
+const (
+	sysIoUringSetup = 425
+	sysIoUringEnter = 426
+
+	ioringOffSqRing = 0
+	ioringOffCqRing = 0x8000000
+	ioringOffSqes   = 0x10000000
+
+	ioringOpRead         = 22
+	ioringEnterGetevents = 1
+
+	uringEntries = 64
+	sqeSize      = 64
+	cqeSize      = 16
+)
+
+//struct io_uring_params, with the io_sqring_offsets at 40 and the
+//io_cqring_offsets at 80
+type uringParams [120]byte
+
+func (p *uringParams) field(off int) int {
+	return int(*(*uint32)(unsafe.Pointer(&p[off])))
+}
+
+type uringRequest struct {
+	fd   int
+	buf  []byte
+	off  int64
+	done chan uringResult
+}
+
+type uringResult struct {
+	n   int
+	err error
+}
+
+type uring struct {
+	fd       int
+	sq, cq   []byte
+	sqes     []byte
+	sqHead   *uint32
+	sqTail   *uint32
+	sqMask   uint32
+	sqArray  int
+	cqHead   *uint32
+	cqTail   *uint32
+	cqMask   uint32
+	cqes     int
+	requests chan *uringRequest
+}
+
+var theUring *uring
+
+//Set up the shared ring. Reads fall back to ordinary syscalls if this fails.
+func startUring() error {
+	var p uringParams
+	fd, _, errno := syscall.Syscall(sysIoUringSetup, uringEntries, uintptr(unsafe.Pointer(&p[0])), 0)
+	if errno != 0 {
+		return os.NewSyscallError("io_uring_setup", errno)
+	}
+
+	r := &uring{fd: int(fd), requests: make(chan *uringRequest, uringEntries)}
+	var sqEntries, cqEntries = p.field(0), p.field(4)
+	var err error
+	mmap := func(offset int64, size int) []byte {
+		if err != nil {
+			return nil
+		}
+		var b []byte
+		b, err = syscall.Mmap(r.fd, offset, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE)
+		return b
+	}
+	r.sq = mmap(ioringOffSqRing, p.field(40+24)+sqEntries*4)
+	r.cq = mmap(ioringOffCqRing, p.field(80+20)+cqEntries*cqeSize)
+	r.sqes = mmap(ioringOffSqes, sqEntries*sqeSize)
+	if err != nil {
+		syscall.Close(r.fd)
+		return os.NewSyscallError("mmap", err)
+	}
+
+	r.sqHead = (*uint32)(unsafe.Pointer(&r.sq[p.field(40)]))
+	r.sqTail = (*uint32)(unsafe.Pointer(&r.sq[p.field(40+4)]))
+	r.sqMask = *(*uint32)(unsafe.Pointer(&r.sq[p.field(40+8)]))
+	r.sqArray = p.field(40 + 24)
+	r.cqHead = (*uint32)(unsafe.Pointer(&r.cq[p.field(80)]))
+	r.cqTail = (*uint32)(unsafe.Pointer(&r.cq[p.field(80+4)]))
+	r.cqMask = *(*uint32)(unsafe.Pointer(&r.cq[p.field(80+8)]))
+	r.cqes = p.field(80 + 20)
+
+	theUring = r
+	go r.loop()
+	return nil
+}
+
+//Read f through the shared ring when it is running
+func uringFile(f *os.File) io.ReadCloser {
+	if theUring == nil {
+		return f
+	}
+	return &uringReader{File: f}
+}
+
+type uringReader struct {
+	*os.File
+	off int64
+}
+
+func (u *uringReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	req := &uringRequest{int(u.File.Fd()), p, u.off, make(chan uringResult, 1)}
+	theUring.requests <- req
+	res := <-req.done
+	u.off += int64(res.n)
+	if res.err != nil {
+		return res.n, &os.PathError{Op: "read", Path: u.File.Name(), Err: res.err}
+	}
+	if res.n == 0 {
+		return 0, io.EOF
+	}
+	return res.n, nil
+}
+
+//Queue every waiting request, submit them together and hand out the
+//completions, for as long as the program runs.
+func (r *uring) loop() {
+	inflight := make(map[uint64]*uringRequest)
+	var nextID uint64
+	for {
+		var req *uringRequest
+		if len(inflight) == 0 {
+			//nothing to wait for, so wait for work
+			req = <-r.requests
+		}
+
+		var queued uint32
+		for len(inflight) < uringEntries {
+			if req == nil {
+				select {
+				case req = <-r.requests:
+				default:
+				}
+				if req == nil {
+					break
+				}
+			}
+			r.queue(req, nextID, queued)
+			inflight[nextID] = req
+			nextID++
+			queued++
+			req = nil
+		}
+		atomic.StoreUint32(r.sqTail, atomic.LoadUint32(r.sqTail)+queued)
+
+		for {
+			_, _, errno := syscall.Syscall6(sysIoUringEnter, uintptr(r.fd), uintptr(queued), 1, ioringEnterGetevents, 0, 0)
+			if errno == syscall.EINTR || errno == syscall.EAGAIN {
+				continue
+			}
+			if errno != 0 {
+				for id, req := range inflight {
+					req.done <- uringResult{0, errno}
+					delete(inflight, id)
+				}
+			}
+			break
+		}
+
+		head := atomic.LoadUint32(r.cqHead)
+		for tail := atomic.LoadUint32(r.cqTail); head != tail; head++ {
+			cqe := r.cqes + int(head&r.cqMask)*cqeSize
+			id := *(*uint64)(unsafe.Pointer(&r.cq[cqe]))
+			res := *(*int32)(unsafe.Pointer(&r.cq[cqe+8]))
+			if req, ok := inflight[id]; ok {
+				delete(inflight, id)
+				if res < 0 {
+					req.done <- uringResult{0, syscall.Errno(-res)}
+				} else {
+					req.done <- uringResult{int(res), nil}
+				}
+			}
+		}
+		atomic.StoreUint32(r.cqHead, head)
+	}
+}
+
+//Fill in the submission queue entry queued places past the tail. It is
+//published when the tail moves.
+func (r *uring) queue(req *uringRequest, id uint64, queued uint32) {
+	index := (atomic.LoadUint32(r.sqTail) + queued) & r.sqMask
+	sqe := r.sqes[index*sqeSize : (index+1)*sqeSize]
+	for i := range sqe {
+		sqe[i] = 0
+	}
+	sqe[0] = ioringOpRead
+	*(*int32)(unsafe.Pointer(&sqe[4])) = int32(req.fd)
+	*(*uint64)(unsafe.Pointer(&sqe[8])) = uint64(req.off)
+	*(*uint64)(unsafe.Pointer(&sqe[16])) = uint64(uintptr(unsafe.Pointer(&req.buf[0])))
+	*(*uint32)(unsafe.Pointer(&sqe[24])) = uint32(len(req.buf))
+	*(*uint64)(unsafe.Pointer(&sqe[32])) = id
+	*(*uint32)(unsafe.Pointer(&r.sq[r.sqArray+int(index)*4])) = index
+}
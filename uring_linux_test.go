@@ -0,0 +1,89 @@
+//go:build uring
+// +build uring
+
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//Many small files, where io_uring should save the most syscalls
+func smallFiles(b *testing.B) []string {
+	b.Helper()
+	var dir = b.TempDir()
+	var data = make([]byte, 4096)
+	var names []string
+	for i := 0; i < 256; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("file%d", i))
+		if err := ioutil.WriteFile(name, data, 0644); err != nil {
+			b.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func benchmarkRead(b *testing.B, open func(*os.File) io.ReadCloser) {
+	var names = smallFiles(b)
+	var buf = make([]byte, 32*1024)
+	b.SetBytes(int64(len(names)) * 4096)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, name := range names {
+			f, err := os.Open(name)
+			if err != nil {
+				b.Fatal(err)
+			}
+			r := open(f)
+			_, err = io.CopyBuffer(ioutil.Discard, r, buf)
+			r.Close()
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkReadPlain(b *testing.B) {
+	benchmarkRead(b, func(f *os.File) io.ReadCloser { return f })
+}
+
+func BenchmarkReadUring(b *testing.B) {
+	if theUring == nil {
+		if err := startUring(); err != nil {
+			b.Skip(err)
+		}
+	}
+	benchmarkRead(b, uringFile)
+}
@@ -0,0 +1,83 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//The -newer-than and -older-than window; zero times leave that side open
+var newerThan, olderThan time.Time
+
+//FILEs left out for being outside the window. openFilesForHashing counts
+//them before it closes in, and main reads the count once in is drained.
+var outsideWindow int
+
+//Parse an RFC 3339 time such as 2024-01-02T15:04:05Z, or a duration before
+//now such as 90m, 24h or 7d
+func parseWhen(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if strings.HasSuffix(s, "d") {
+		if days, err := strconv.ParseFloat(strings.TrimSuffix(s, "d"), 64); err == nil && days >= 0 {
+			return time.Now().Add(-time.Duration(days * float64(24*time.Hour))), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 time nor a duration like 24h or 7d", s)
+	}
+	return time.Now().Add(-d), nil
+}
+
+//Drop the names whose modification time is outside the window. Names that
+//can't be looked at are kept, for opening them to fail as usual.
+func inWindow(names []string) []string {
+	if newerThan.IsZero() && olderThan.IsZero() {
+		return names
+	}
+	var kept []string
+	for _, name := range names {
+		var file = name
+		if flag.NArg() > 0 {
+			_, file = argAlgo(name)
+		}
+		fi, err := os.Stat(file)
+		if err == nil && (!newerThan.IsZero() && !fi.ModTime().After(newerThan) || !olderThan.IsZero() && !fi.ModTime().Before(olderThan)) {
+			outsideWindow++
+			continue
+		}
+		kept = append(kept, name)
+	}
+	return kept
+}
@@ -0,0 +1,144 @@
+/*
+Copyright (c) 2014, Gregory L. Dietsche
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+//ZIP record signatures
+const (
+	zipLocalSig   = 0x04034b50
+	zipCentralSig = 0x02014b50
+	zipEndSig     = 0x06054b50
+)
+
+//Zero the timestamps of a zip archive for -ignore-metadata-fields zip, so
+//archives of the same files made at different times hash the same. In both
+//the local and central header of every entry, these are zeroed:
+//
+//	the DOS modification time and date
+//	all of the extended timestamp extra field (0x5455)
+//	all of the NTFS extra field (0x000a), which holds only times
+//	the access and modification times of the Unix extra fields (0x000d, 0x5855)
+//
+//Everything else, including the archive comment, is hashed as it is. Zip64
+//archives are not supported.
+func stableZip(r io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var damaged = errors.New("not a zip archive, or a damaged one")
+
+	var end = -1
+	for i := len(data) - 22; i >= 0 && i >= len(data)-22-0xffff; i-- {
+		if binary.LittleEndian.Uint32(data[i:]) == zipEndSig {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return nil, damaged
+	}
+	var entries = int(binary.LittleEndian.Uint16(data[end+10:]))
+	var offset = binary.LittleEndian.Uint32(data[end+16:])
+	if entries == 0xffff || offset == 0xffffffff {
+		return nil, errors.New("zip64 archives are not supported")
+	}
+	if int64(offset) > int64(len(data)) {
+		return nil, damaged
+	}
+	var p = int(offset)
+
+	for ; entries > 0; entries-- {
+		if p+46 > len(data) || binary.LittleEndian.Uint32(data[p:]) != zipCentralSig {
+			return nil, damaged
+		}
+		var nameLen = int(binary.LittleEndian.Uint16(data[p+28:]))
+		var extraLen = int(binary.LittleEndian.Uint16(data[p+30:]))
+		var commentLen = int(binary.LittleEndian.Uint16(data[p+32:]))
+		var localOffset = binary.LittleEndian.Uint32(data[p+42:])
+		if int64(localOffset) > int64(len(data)) {
+			return nil, damaged
+		}
+		var local = int(localOffset)
+		if p+46+nameLen+extraLen > len(data) {
+			return nil, damaged
+		}
+		zeroDOSTime(data[p+12:])
+		zeroExtraTimes(data[p+46+nameLen : p+46+nameLen+extraLen])
+		p += 46 + nameLen + extraLen + commentLen
+
+		if local+30 > len(data) || binary.LittleEndian.Uint32(data[local:]) != zipLocalSig {
+			return nil, damaged
+		}
+		nameLen = int(binary.LittleEndian.Uint16(data[local+26:]))
+		extraLen = int(binary.LittleEndian.Uint16(data[local+28:]))
+		if local+30+nameLen+extraLen > len(data) {
+			return nil, damaged
+		}
+		zeroDOSTime(data[local+10:])
+		zeroExtraTimes(data[local+30+nameLen : local+30+nameLen+extraLen])
+	}
+	return data, nil
+}
+
+//Zero a DOS time and date, 2 bytes each
+func zeroDOSTime(b []byte) {
+	copy(b[:4], []byte{0, 0, 0, 0})
+}
+
+//Zero the timestamps held in a zip extra field block, a run of fields each
+//of a 2 byte ID, a 2 byte length and that much data
+func zeroExtraTimes(extra []byte) {
+	for len(extra) >= 4 {
+		var id = binary.LittleEndian.Uint16(extra)
+		var n = int(binary.LittleEndian.Uint16(extra[2:]))
+		if 4+n > len(extra) {
+			return
+		}
+		var field = extra[4 : 4+n]
+		switch id {
+		case 0x5455, 0x000a:
+			//nothing but times
+		case 0x000d, 0x5855:
+			if len(field) > 8 {
+				field = field[:8]
+			}
+		default:
+			field = nil
+		}
+		for i := range field {
+			field[i] = 0
+		}
+		extra = extra[4+n:]
+	}
+}